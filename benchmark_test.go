@@ -0,0 +1,148 @@
+package Netpbm
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"io"
+	"testing"
+)
+
+/*The benchmarks run on synthetic 1024x1024 images built through the image adapters.*/
+const benchmarkSize = 1024
+
+func benchmarkSource() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, benchmarkSize, benchmarkSize))
+	for y := 0; y < benchmarkSize; y++ {
+		for x := 0; x < benchmarkSize; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), uint8(x ^ y), 255})
+		}
+	}
+	return img
+}
+
+func benchmarkPPM(b *testing.B) *PPM {
+	b.Helper()
+	ppm := NewPPMFromImage(benchmarkSource())
+	ppm.SetMagicNumber("P6")
+	return ppm
+}
+
+func benchmarkPGM(b *testing.B) *PGM {
+	b.Helper()
+	pgm := NewPGMFromImage(benchmarkSource())
+	pgm.SetMagicNumber("P5")
+	return pgm
+}
+
+func benchmarkPBM(b *testing.B) *PBM {
+	b.Helper()
+	pbm := NewPBMFromImage(benchmarkSource())
+	pbm.SetMagicNumber("P4")
+	return pbm
+}
+
+func BenchmarkPPMFlip(b *testing.B) {
+	ppm := benchmarkPPM(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ppm.Flip()
+	}
+}
+
+func BenchmarkPPMFlop(b *testing.B) {
+	ppm := benchmarkPPM(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ppm.Flop()
+	}
+}
+
+func BenchmarkPPMRotate90CW(b *testing.B) {
+	ppm := benchmarkPPM(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ppm.Rotate90CW()
+	}
+}
+
+func BenchmarkPPMToPGM(b *testing.B) {
+	ppm := benchmarkPPM(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ppm.ToPGM()
+	}
+}
+
+func BenchmarkPGMFlip(b *testing.B) {
+	pgm := benchmarkPGM(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		pgm.Flip()
+	}
+}
+
+func BenchmarkPGMRotate90CW(b *testing.B) {
+	pgm := benchmarkPGM(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		pgm.Rotate90CW()
+	}
+}
+
+func BenchmarkPGMToPBM(b *testing.B) {
+	pgm := benchmarkPGM(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		pgm.ToPBM()
+	}
+}
+
+func BenchmarkPBMFlop(b *testing.B) {
+	pbm := benchmarkPBM(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		pbm.Flop()
+	}
+}
+
+func BenchmarkPPMEncodeP6(b *testing.B) {
+	ppm := benchmarkPPM(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ppm.Encode(io.Discard)
+	}
+}
+
+func BenchmarkPPMDecodeP6(b *testing.B) {
+	var buffer bytes.Buffer
+	benchmarkPPM(b).Encode(&buffer)
+	b.SetBytes(int64(buffer.Len()))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := DecodePPM(bytes.NewReader(buffer.Bytes())); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPGMDecodeP5(b *testing.B) {
+	var buffer bytes.Buffer
+	benchmarkPGM(b).Encode(&buffer)
+	b.SetBytes(int64(buffer.Len()))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := DecodePGM(bytes.NewReader(buffer.Bytes())); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPPMEncodeP3(b *testing.B) {
+	ppm := benchmarkPPM(b)
+	ppm.SetMagicNumber("P3")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ppm.Encode(io.Discard)
+	}
+}
@@ -0,0 +1,71 @@
+package Netpbm
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	gzipMagic  = []byte{0x1f, 0x8b}
+	bzip2Magic = []byte("BZh")
+)
+
+/*
+decompress wraps reader in a gzip or bzip2 reader when its first bytes say
+so, and returns it unchanged otherwise; the boolean tells which. Netpbm
+magic numbers start with 'P', so no image can be taken for a compressed
+stream.
+*/
+func decompress(reader *bufio.Reader) (*bufio.Reader, bool) {
+	magic, _ := reader.Peek(len(bzip2Magic))
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		gzipReader, err := gzip.NewReader(reader)
+		if err != nil {
+			return bufio.NewReader(errorReader{err}), true
+		}
+		return bufio.NewReader(gzipReader), true
+	case bytes.HasPrefix(magic, bzip2Magic):
+		return bufio.NewReader(bzip2.NewReader(reader)), true
+	}
+	return reader, false
+}
+
+/*errorReader fails every read, it carries a bad compressed header to the first token.*/
+type errorReader struct {
+	err error
+}
+
+func (r errorReader) Read(p []byte) (int, error) {
+	return 0, r.err
+}
+
+// ErrCompressionNotSupported is returned by Save for an extension it can only read, such as .bz2.
+var ErrCompressionNotSupported = errors.New("compression not supported for writing")
+
+/*
+compressFor wraps encode so that it writes gzip when filename ends in .gz.
+The standard library has no bzip2 or zstd writer, so .bz2 and .zst are
+refused rather than written uncompressed.
+*/
+func compressFor(filename string, encode func(io.Writer) error) (func(io.Writer) error, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".gz":
+		return func(w io.Writer) error {
+			gzipWriter := gzip.NewWriter(w)
+			if err := encode(gzipWriter); err != nil {
+				return err
+			}
+			return gzipWriter.Close()
+		}, nil
+	case ".bz2", ".zst":
+		return nil, ErrCompressionNotSupported
+	}
+	return encode, nil
+}
@@ -0,0 +1,104 @@
+package Netpbm
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+/*"P2\n2 1\n255\n7 9\n" compressed by bzip2 -9*/
+var bzip2PGM = []byte{
+	0x42, 0x5a, 0x68, 0x39, 0x31, 0x41, 0x59, 0x26, 0x53, 0x59, 0x70, 0x3d, 0x50, 0x3a, 0x00, 0x00,
+	0x07, 0x5a, 0x00, 0x00, 0x10, 0x40, 0x00, 0x32, 0xa0, 0x40, 0x00, 0x20, 0x00, 0x22, 0x06, 0x4f,
+	0x48, 0x43, 0x02, 0x12, 0x62, 0x09, 0x28, 0xed, 0x79, 0xe2, 0xee, 0x48, 0xa7, 0x0a, 0x12, 0x0e,
+	0x07, 0xaa, 0x07, 0x40,
+}
+
+func TestSaveReadGzip(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "image.pgm.gz")
+	pgm := NewPGM(3, 2, 255)
+	pgm.Set(2, 1, 42)
+	if err := pgm.Save(filename, Atomic()); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(content, gzipMagic) {
+		t.Fatalf("Not gzip compressed: %q", content)
+	}
+
+	saved, err := ReadPGM(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.At(2, 1) != 42 {
+		t.Error("Wrong data")
+	}
+	header, err := ReadHeader(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Width != 3 || header.Height != 2 || header.DataOffset != -1 {
+		t.Errorf("Wrong header %+v", header)
+	}
+	if _, err := Read(filename); err != nil {
+		t.Error(err)
+	}
+
+	/*The magic bytes decide, not the extension*/
+	renamed := filepath.Join(filepath.Dir(filename), "image.pgm")
+	os.Rename(filename, renamed)
+	if _, err := ReadPGM(renamed); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDecodeDecompression(t *testing.T) {
+	var buffer bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buffer)
+	gzipWriter.Write([]byte("P1\n2 1\n10\n"))
+	gzipWriter.Close()
+	compressed := buffer.Bytes()
+
+	if _, err := DecodePBM(bytes.NewReader(compressed)); !errors.Is(err, ErrBadMagic) {
+		t.Errorf("Expected ErrBadMagic without the option, got %v", err)
+	}
+	pbm, err := DecodePBM(bytes.NewReader(compressed), WithDecompression())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pbm.At(0, 0) || pbm.At(1, 0) {
+		t.Error("Wrong data")
+	}
+
+	pgm, err := DecodePGM(bytes.NewReader(bzip2PGM), WithDecompression())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pgm.At(0, 0) != 7 || pgm.At(1, 0) != 9 {
+		t.Error("Wrong data")
+	}
+
+	/*Uncompressed input goes through unchanged*/
+	if _, err := DecodePGM(bytes.NewReader([]byte("P2\n1 1\n255\n0\n")), WithDecompression()); err != nil {
+		t.Error(err)
+	}
+	if _, err := DecodePGM(bytes.NewReader(compressed[:5]), WithDecompression()); !errors.Is(err, ErrTruncated) {
+		t.Errorf("Expected ErrTruncated, got %v", err)
+	}
+}
+
+func TestSaveCompressionNotSupported(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "image.ppm.bz2")
+	if err := NewPPM(1, 1, 255).Save(filename); !errors.Is(err, ErrCompressionNotSupported) {
+		t.Errorf("Expected ErrCompressionNotSupported, got %v", err)
+	}
+	if _, err := os.Stat(filename); !os.IsNotExist(err) {
+		t.Error("File should not be created")
+	}
+}
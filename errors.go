@@ -0,0 +1,53 @@
+package Netpbm
+
+import (
+	"errors"
+	"fmt"
+)
+
+/*
+The sentinel errors classify why decoding failed; every decoder error wraps
+one of them, so they can be tested with errors.Is.
+*/
+var (
+	ErrBadMagic         = errors.New("bad magic number")
+	ErrTruncated        = errors.New("truncated data")
+	ErrBadDimensions    = errors.New("bad dimensions")
+	ErrBadMaxValue      = errors.New("bad max value")
+	ErrSampleOutOfRange = errors.New("sample out of range")
+	ErrSyntax           = errors.New("syntax error")
+)
+
+/*
+ParseError says where and why decoding failed. Line and Column, both
+starting at 1, point into the text part of the file; they are 0 for errors
+inside a raw raster, where only Offset is meaningful. File is only set by
+the Read functions.
+*/
+type ParseError struct {
+	File         string
+	Offset       int64
+	Line, Column int
+	Expected     string
+	Found        string
+	Err          error
+}
+
+func (e *ParseError) Error() string {
+	position := fmt.Sprintf("offset %d", e.Offset)
+	if e.Line > 0 {
+		position = fmt.Sprintf("%d:%d", e.Line, e.Column)
+	}
+	if e.File != "" {
+		position = e.File + ":" + position
+	}
+	message := position + ": "
+	if e.Err != nil {
+		message += e.Err.Error() + ": "
+	}
+	return message + fmt.Sprintf("expected %s, found %s", e.Expected, e.Found)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
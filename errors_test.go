@@ -0,0 +1,73 @@
+package Netpbm
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParseErrorSentinels(t *testing.T) {
+	inputs := map[string]error{
+		"":                        ErrTruncated,
+		"P5\n":                    ErrTruncated,
+		"P8\n1 1\n":               ErrBadMagic,
+		"P2\n0 1\n255\n":          ErrBadDimensions,
+		"P2\nwide 1\n255\n":       ErrBadDimensions,
+		"P2\n1 1\n70000\n":        ErrBadMaxValue,
+		"P2\n2 1\n10\n3 11\n":     ErrSampleOutOfRange,
+		"P2\n2 1\n10\n3 x\n":      ErrSyntax,
+		"P1\n2 1\n0 x\n":          ErrSyntax,
+		"P1\n2 1\n0 2\n":          ErrSampleOutOfRange,
+		"P5\n2 1\n10\n\x03\x0b":   ErrSampleOutOfRange,
+		"P6\n2 1\n255\n\x00\x00":  ErrTruncated,
+		"P7\nWIDTH 1\nENDHDR\n":   ErrBadDimensions,
+		"P7\nWIDTH 1\nHEIGHT 1\n": ErrTruncated,
+		"P7\nWIDTH 1\nCOLORS 3\n": ErrSyntax,
+		"GIF89a":                  ErrBadMagic,
+		"P4\n9 1\n\x00":           ErrTruncated,
+		"P3\n1 1\n255\n1 2":       ErrTruncated,
+		"P7\nWIDTH 1\nHEIGHT 1\nDEPTH 1\nMAXVAL 0\nENDHDR\n": ErrBadMaxValue,
+		"P2\n1 1\n255\n+5\n": ErrSyntax,
+		"P2\n-1 1\n255\n":    ErrBadDimensions,
+		"P7\nWIDTH +1\n":     ErrSyntax,
+	}
+	for input, sentinel := range inputs {
+		_, err := Decode(strings.NewReader(input))
+		if !errors.Is(err, sentinel) {
+			t.Errorf("%q: expected %v, got %v", input, sentinel, err)
+		}
+	}
+}
+
+func TestParseErrorPosition(t *testing.T) {
+	_, err := DecodePGM(strings.NewReader("P2\n# comment\n2 2\n255\n1 2\n3 300\n"))
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("Expected a ParseError, got %v", err)
+	}
+	if parseErr.Line != 6 || parseErr.Column != 3 || parseErr.Offset != 27 || parseErr.Found != "300" {
+		t.Errorf("Wrong position %+v", parseErr)
+	}
+	if err.Error() != "6:3: sample out of range: expected sample from 0 to 255, found 300" {
+		t.Errorf("Wrong message %q", err.Error())
+	}
+
+	/*Raw data errors only carry an offset*/
+	_, err = DecodePPM(strings.NewReader("P6\n1 2\n255\n\x01\x02\x03\x04"))
+	if !errors.As(err, &parseErr) || parseErr.Line != 0 || parseErr.Offset != 15 {
+		t.Errorf("Wrong raw position %+v", parseErr)
+	}
+}
+
+func TestParseErrorFile(t *testing.T) {
+	filename := t.TempDir() + "/bad.pbm"
+	if err := os.WriteFile(filename, []byte("P4\n8 8\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := Read(filename)
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || parseErr.File != filename || !strings.HasPrefix(err.Error(), filename+":offset 7:") {
+		t.Errorf("File name not recorded: %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package Netpbm
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"testing"
+	"testing/fstest"
+)
+
+func TestReadFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"icons/dot.pbm":  {Data: []byte("P1\n# icon\n2 1\n10\n")},
+		"icons/gray.pgm": {Data: []byte("P5\n1 1\n255\n\x80")},
+		"icons/red.ppm":  {Data: []byte("P3\n1 1\n255\n255 0 0\n")},
+		"icons/red.pam":  {Data: []byte("P7\nWIDTH 1\nHEIGHT 1\nDEPTH 3\nMAXVAL 255\nTUPLTYPE RGB\nENDHDR\n\xff\x00\x00")},
+		"icons/hdr.pfm":  {Data: []byte("Pf\n1 1\n-1\n\x00\x00\x80\x3f")},
+		"icons/bad.pgm":  {Data: []byte("P2\n1 1\n255\n256\n")},
+	}
+
+	pbm, err := ReadPBMFS(fsys, "icons/dot.pbm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pbm.At(0, 0) || pbm.At(1, 0) || len(pbm.Comments) != 1 {
+		t.Error("Wrong bitmap")
+	}
+	pgm, err := ReadPGMFS(fsys, "icons/gray.pgm")
+	if err != nil || pgm.At(0, 0) != 0x80 {
+		t.Errorf("Wrong graymap: %v", err)
+	}
+	ppm, err := ReadPPMFS(fsys, "icons/red.ppm")
+	if err != nil || ppm.At(0, 0) != (Pixel{255, 0, 0}) {
+		t.Errorf("Wrong pixmap: %v", err)
+	}
+	pam, err := ReadPAMFS(fsys, "icons/red.pam")
+	if err != nil || pam.At(0, 0)[0] != 255 {
+		t.Errorf("Wrong PAM: %v", err)
+	}
+	pfm, err := ReadPFMFS(fsys, "icons/hdr.pfm")
+	if err != nil || pfm.At(0, 0)[0] != 1 {
+		t.Errorf("Wrong float map: %v", err)
+	}
+	img, err := ReadFS(fsys, "icons/red.ppm")
+	if _, ok := img.(*PPM); err != nil || !ok {
+		t.Errorf("Wrong image %T: %v", img, err)
+	}
+	header, err := ReadHeaderFS(fsys, "icons/red.pam")
+	if err != nil || header.TupleType != "RGB" {
+		t.Errorf("Wrong header %+v: %v", header, err)
+	}
+
+	var parseErr *ParseError
+	if _, err := ReadPGMFS(fsys, "icons/bad.pgm"); !errors.As(err, &parseErr) || parseErr.File != "icons/bad.pgm" {
+		t.Errorf("Expected a ParseError naming the file, got %v", err)
+	}
+	if _, err := ReadPBMFS(fsys, "icons/missing.pbm"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected fs.ErrNotExist, got %v", err)
+	}
+}
+
+func TestReadFSDir(t *testing.T) {
+	pbm, err := ReadPBMFS(os.DirFS("testImages"), "pbm/testP4.pbm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < imageWidth*imageHeight; i++ {
+		if pbm.At(i%imageWidth, i/imageWidth) != imageDataP1[i] {
+			t.Fatal("Wrong data")
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package Netpbm
+
+import (
+	"bufio"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+/*
+Header is what DecodeHeader learns about an image without reading its
+raster. DataOffset is the byte offset of the first raster byte, right after
+the single whitespace that ends the header; it is -1 for a compressed file,
+where no offset in the file leads to the raster. Depth is the number of samples
+per pixel: 1 for bitmaps and graymaps, 3 for pixmaps, and DEPTH for P7.
+*/
+type Header struct {
+	MagicNumber   string
+	Width, Height int
+	MaxValue      uint16 /*1 for bitmaps*/
+	Depth         int
+	TupleType     string /*P7 only*/
+	DataOffset    int64
+	Comments      []string
+}
+
+// ReadHeader opens filename and decodes its header only, see DecodeHeader.
+func ReadHeader(filename string, opts ...DecodeOption) (*Header, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return headerFrom(newTokenizer(file, fileOptions(filename, opts)...))
+}
+
+// ReadHeaderFS is ReadHeader for a file of fsys, such as an embed.FS.
+func ReadHeaderFS(fsys fs.FS, name string, opts ...DecodeOption) (*Header, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return headerFrom(newTokenizer(file, fileOptions(name, opts)...))
+}
+
+// DecodeHeader reads the header of a P1 to P7 image from r and stops before the raster; opts apply as in Decode.
+func DecodeHeader(r io.Reader, opts ...DecodeOption) (*Header, error) {
+	return headerFrom(newTokenizer(r, opts...))
+}
+
+func headerFrom(tok *tokenizer) (*Header, error) {
+	magicNumber, err := tok.nextMagic()
+	if err != nil {
+		return nil, err
+	}
+	return decodeHeader(tok, magicNumber)
+}
+
+func decodeHeader(tok *tokenizer, magicNumber string) (*Header, error) {
+	header := &Header{MagicNumber: magicNumber, MaxValue: 1, Depth: 1}
+	switch magicNumber {
+	case "P1", "P2", "P4", "P5":
+	case "P3", "P6":
+		header.Depth = 3
+	case "P7":
+		pam, err := decodePAMHeader(tok, magicNumber)
+		if err != nil {
+			return nil, err
+		}
+		header.Width, header.Height = pam.width, pam.height
+		header.MaxValue, header.Depth, header.TupleType = pam.max, pam.depth, pam.tupleType
+		header.DataOffset = tok.dataOffset()
+		header.Comments = tok.comments
+		return header, nil
+	default:
+		return nil, &UnknownFormatError{MagicNumber: magicNumber}
+	}
+
+	var err error
+	if header.Width, err = tok.nextDimension("width"); err != nil {
+		return nil, err
+	}
+	if header.Height, err = tok.nextDimension("height"); err != nil {
+		return nil, err
+	}
+	if magicNumber != "P1" && magicNumber != "P4" {
+		if header.MaxValue, err = tok.nextMaxValue(); err != nil {
+			return nil, err
+		}
+	}
+	header.DataOffset = tok.dataOffset()
+	header.Comments = tok.comments
+	return header, nil
+}
+
+/*
+writeComments writes one "# " line per comment. A comment spanning several
+lines is split so that it cannot end the comment early and corrupt the header.
+*/
+func writeComments(writer *bufio.Writer, comments []string) {
+	for _, comment := range comments {
+		for _, line := range strings.Split(strings.ReplaceAll(comment, "\r", "\n"), "\n") {
+			if line = strings.TrimSpace(line); line == "" {
+				writer.WriteString("#\n")
+			} else {
+				writer.WriteString("# " + line + "\n")
+			}
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package Netpbm
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadHeader(t *testing.T) {
+	files := map[string]Header{
+		"./testImages/pbm/testP1.pbm": {MagicNumber: "P1", Width: 15, Height: 15, MaxValue: 1, DataOffset: 9},
+		"./testImages/pbm/testP4.pbm": {MagicNumber: "P4", Width: 15, Height: 15, MaxValue: 1, DataOffset: 9},
+		"./testImages/pgm/testP5.pgm": {MagicNumber: "P5", Width: 15, Height: 15, MaxValue: 11, DataOffset: 12},
+		"./testImages/ppm/testP6.ppm": {MagicNumber: "P6", Width: 15, Height: 15, MaxValue: 255, DataOffset: 13},
+	}
+	for filename, expected := range files {
+		header, err := ReadHeader(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if header.MagicNumber != expected.MagicNumber || header.Width != expected.Width || header.Height != expected.Height ||
+			header.MaxValue != expected.MaxValue || header.DataOffset != expected.DataOffset {
+			t.Errorf("%s: wrong header %+v", filename, header)
+		}
+	}
+}
+
+func TestDecodeHeaderComments(t *testing.T) {
+	header, err := DecodeHeader(strings.NewReader("P6\n# creator: scanner\n4 # width\n2\n# depth\n65535\n\x00\x01"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Width != 4 || header.Height != 2 || header.MaxValue != 65535 || header.DataOffset != 48 {
+		t.Errorf("Wrong header %+v", header)
+	}
+	expected := []string{"creator: scanner", "width", "depth"}
+	if len(header.Comments) != len(expected) {
+		t.Fatalf("Wrong comments %q", header.Comments)
+	}
+	for i := range expected {
+		if header.Comments[i] != expected[i] {
+			t.Errorf("Wrong comment, expected %q got %q", expected[i], header.Comments[i])
+		}
+	}
+
+	if _, err := DecodeHeader(strings.NewReader("P9 1 1 1\n")); err == nil {
+		t.Error("Expected an error for an unknown magic number")
+	}
+}
+
+func TestDecodeHeaderPAM(t *testing.T) {
+	header, err := DecodeHeader(strings.NewReader("P7\nWIDTH 3\nHEIGHT 2\nDEPTH 4\nMAXVAL 255\nTUPLTYPE RGB_ALPHA\nENDHDR\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Width != 3 || header.Height != 2 || header.Depth != 4 || header.TupleType != "RGB_ALPHA" || header.DataOffset != 65 {
+		t.Errorf("Wrong header %+v", header)
+	}
+}
+
+func TestDecodeHeaderOptions(t *testing.T) {
+	var buffer bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buffer)
+	gzipWriter.Write([]byte("P2\n3 2\n70000\n"))
+	gzipWriter.Close()
+
+	if _, err := DecodeHeader(bytes.NewReader(buffer.Bytes()), WithDecompression()); !errors.Is(err, ErrBadMaxValue) {
+		t.Errorf("Expected ErrBadMaxValue once decompressed, got %v", err)
+	}
+	var warnings []*ParseError
+	header, err := DecodeHeader(bytes.NewReader(buffer.Bytes()), WithDecompression(), WithMode(Lenient), WithWarnings(&warnings))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Width != 3 || header.MaxValue != 65535 || header.DataOffset != -1 || len(warnings) != 1 {
+		t.Errorf("Wrong header %+v, warnings %v", header, warnings)
+	}
+
+	filename := filepath.Join(t.TempDir(), "huge.pgm")
+	if err := os.WriteFile(filename, []byte("P5\n1 1\n70000\n\x00\x00"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if header, err := ReadHeader(filename, WithMode(Lenient)); err != nil || header.MaxValue != 65535 {
+		t.Errorf("Options not used by ReadHeader: %+v, %v", header, err)
+	}
+	if header, err := ReadHeaderFS(os.DirFS(filepath.Dir(filename)), "huge.pgm", WithMode(Lenient)); err != nil || header.MaxValue != 65535 {
+		t.Errorf("Options not used by ReadHeaderFS: %+v, %v", header, err)
+	}
+}
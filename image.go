@@ -0,0 +1,268 @@
+package Netpbm
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+)
+
+/*
+Registering the magic numbers lets image.Decode and image.DecodeConfig
+recognise Netpbm streams once this package is imported.
+*/
+func init() {
+	image.RegisterFormat("pbm", "P1", decodePBMImage, decodeConfig)
+	image.RegisterFormat("pbm", "P4", decodePBMImage, decodeConfig)
+	image.RegisterFormat("pgm", "P2", decodePGMImage, decodeConfig)
+	image.RegisterFormat("pgm", "P5", decodePGMImage, decodeConfig)
+	image.RegisterFormat("ppm", "P3", decodePPMImage, decodeConfig)
+	image.RegisterFormat("ppm", "P6", decodePPMImage, decodeConfig)
+	image.RegisterFormat("pam", "P7", decodePAMImage, decodePAMConfig)
+}
+
+func decodePBMImage(r io.Reader) (image.Image, error) {
+	pbm, err := DecodePBM(r)
+	if err != nil {
+		return nil, err
+	}
+	return pbm.Image(), nil
+}
+
+func decodePGMImage(r io.Reader) (image.Image, error) {
+	pgm, err := DecodePGM(r)
+	if err != nil {
+		return nil, err
+	}
+	return pgm.Image(), nil
+}
+
+func decodePPMImage(r io.Reader) (image.Image, error) {
+	ppm, err := DecodePPM(r)
+	if err != nil {
+		return nil, err
+	}
+	return ppm.Image(), nil
+}
+
+func decodeConfig(r io.Reader) (image.Config, error) {
+	header, err := DecodeHeader(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+	config := image.Config{Width: header.Width, Height: header.Height}
+	switch header.MagicNumber {
+	case "P1", "P4":
+		config.ColorModel = color.GrayModel
+	case "P2", "P5":
+		config.ColorModel = grayModel(header.MaxValue)
+	case "P3", "P6":
+		config.ColorModel = rgbModel(header.MaxValue)
+	}
+	return config, nil
+}
+
+func grayModel(max uint16) color.Model {
+	if max > 255 {
+		return color.Gray16Model
+	}
+	return color.GrayModel
+}
+
+func rgbModel(max uint16) color.Model {
+	if max > 255 {
+		return color.RGBA64Model
+	}
+	return color.RGBAModel
+}
+
+/*
+scale maps a sample from 0..from to 0..to, rounding to the nearest value
+with halves going up. It is the one rescaling rule of the package, used by
+SetMaxValue as well as the image adapters.
+*/
+func scale(value uint32, from, to uint32) uint32 {
+	return (value*to + from/2) / from
+}
+
+// Image returns a view of the bitmap as a draw.Image; set pixels (true) are black.
+func (pbm *PBM) Image() draw.Image {
+	return pbmImage{pbm}
+}
+
+type pbmImage struct {
+	pbm *PBM
+}
+
+func (img pbmImage) ColorModel() color.Model {
+	return color.GrayModel
+}
+
+func (img pbmImage) Bounds() image.Rectangle {
+	return image.Rect(0, 0, img.pbm.width, img.pbm.height)
+}
+
+func (img pbmImage) At(x, y int) color.Color {
+	if !(image.Point{x, y}.In(img.Bounds())) {
+		return color.Gray{}
+	}
+	if img.pbm.At(x, y) {
+		return color.Gray{Y: 0}
+	}
+	return color.Gray{Y: 255}
+}
+
+func (img pbmImage) Set(x, y int, c color.Color) {
+	if !(image.Point{x, y}.In(img.Bounds())) {
+		return
+	}
+	img.pbm.Set(x, y, color.GrayModel.Convert(c).(color.Gray).Y < 128)
+}
+
+// Image returns a view of the graymap as a draw.Image using color.Gray, or color.Gray16 above 8 bits.
+func (pgm *PGM) Image() draw.Image {
+	return pgmImage{pgm}
+}
+
+type pgmImage struct {
+	pgm *PGM
+}
+
+func (img pgmImage) ColorModel() color.Model {
+	return grayModel(img.pgm.max)
+}
+
+func (img pgmImage) Bounds() image.Rectangle {
+	return image.Rect(0, 0, img.pgm.width, img.pgm.height)
+}
+
+func (img pgmImage) At(x, y int) color.Color {
+	if !(image.Point{x, y}.In(img.Bounds())) {
+		return color.Gray{}
+	}
+	value := uint32(img.pgm.At(x, y))
+	if img.pgm.max > 255 {
+		return color.Gray16{Y: uint16(scale(value, uint32(img.pgm.max), 65535))}
+	}
+	return color.Gray{Y: uint8(scale(value, uint32(img.pgm.max), 255))}
+}
+
+func (img pgmImage) Set(x, y int, c color.Color) {
+	if !(image.Point{x, y}.In(img.Bounds())) {
+		return
+	}
+	gray := color.Gray16Model.Convert(c).(color.Gray16)
+	img.pgm.Set(x, y, uint16(scale(uint32(gray.Y), 65535, uint32(img.pgm.max))))
+}
+
+// Image returns a view of the pixmap as a draw.Image using color.RGBA, or color.RGBA64 above 8 bits.
+func (ppm *PPM) Image() draw.Image {
+	return ppmImage{ppm}
+}
+
+type ppmImage struct {
+	ppm *PPM
+}
+
+func (img ppmImage) ColorModel() color.Model {
+	return rgbModel(img.ppm.max)
+}
+
+func (img ppmImage) Bounds() image.Rectangle {
+	return image.Rect(0, 0, img.ppm.width, img.ppm.height)
+}
+
+func (img ppmImage) At(x, y int) color.Color {
+	if !(image.Point{x, y}.In(img.Bounds())) {
+		return color.RGBA{}
+	}
+	pixel := img.ppm.At(x, y)
+	max := uint32(img.ppm.max)
+	if max > 255 {
+		return color.RGBA64{
+			R: uint16(scale(uint32(pixel.R), max, 65535)),
+			G: uint16(scale(uint32(pixel.G), max, 65535)),
+			B: uint16(scale(uint32(pixel.B), max, 65535)),
+			A: 65535,
+		}
+	}
+	return color.RGBA{
+		R: uint8(scale(uint32(pixel.R), max, 255)),
+		G: uint8(scale(uint32(pixel.G), max, 255)),
+		B: uint8(scale(uint32(pixel.B), max, 255)),
+		A: 255,
+	}
+}
+
+func (img ppmImage) Set(x, y int, c color.Color) {
+	if !(image.Point{x, y}.In(img.Bounds())) {
+		return
+	}
+	img.ppm.Set(x, y, pixelFromColor(c, img.ppm.max))
+}
+
+/*pixelFromColor drops alpha by compositing over black, as color.RGBA already does.*/
+func pixelFromColor(c color.Color, max uint16) Pixel {
+	r, g, b, _ := c.RGBA()
+	return Pixel{
+		R: uint16(scale(r, 65535, uint32(max))),
+		G: uint16(scale(g, 65535, uint32(max))),
+		B: uint16(scale(b, 65535, uint32(max))),
+	}
+}
+
+/*deepModel reports whether img carries more than 8 bits per sample.*/
+func deepModel(img image.Image) bool {
+	switch img.ColorModel() {
+	case color.Gray16Model, color.RGBA64Model, color.NRGBA64Model, color.Alpha16Model:
+		return true
+	}
+	return false
+}
+
+// NewPBMFromImage builds a plain bitmap from img, pixels darker than mid-gray become set.
+func NewPBMFromImage(img image.Image) *PBM {
+	bounds := img.Bounds()
+	pbm := NewPBM(bounds.Dx(), bounds.Dy())
+	for y := 0; y < pbm.height; y++ {
+		row := pbm.Row(y)
+		for x := range row {
+			gray := color.Gray16Model.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.Gray16)
+			row[x] = gray.Y < 0x8000
+		}
+	}
+	return pbm
+}
+
+// NewPGMFromImage builds a plain graymap from img, with a max value of 65535 for 16-bit sources and 255 otherwise.
+func NewPGMFromImage(img image.Image) *PGM {
+	bounds := img.Bounds()
+	pgm := NewPGM(bounds.Dx(), bounds.Dy(), 255)
+	if deepModel(img) {
+		pgm.max = 65535
+	}
+	for y := 0; y < pgm.height; y++ {
+		row := pgm.Row(y)
+		for x := range row {
+			gray := color.Gray16Model.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.Gray16)
+			row[x] = uint16(scale(uint32(gray.Y), 65535, uint32(pgm.max)))
+		}
+	}
+	return pgm
+}
+
+// NewPPMFromImage builds a plain pixmap from img, with a max value of 65535 for 16-bit sources and 255 otherwise.
+func NewPPMFromImage(img image.Image) *PPM {
+	bounds := img.Bounds()
+	ppm := NewPPM(bounds.Dx(), bounds.Dy(), 255)
+	if deepModel(img) {
+		ppm.max = 65535
+	}
+	for y := 0; y < ppm.height; y++ {
+		row := ppm.Row(y)
+		for x := range row {
+			row[x] = pixelFromColor(img.At(bounds.Min.X+x, bounds.Min.Y+y), ppm.max)
+		}
+	}
+	return ppm
+}
@@ -0,0 +1,121 @@
+package Netpbm
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"os"
+	"testing"
+)
+
+func TestImageDecode(t *testing.T) {
+	files := map[string]string{
+		"./testImages/pbm/testP4.pbm": "pbm",
+		"./testImages/pgm/testP2.pgm": "pgm",
+		"./testImages/ppm/testP6.ppm": "ppm",
+	}
+	for filename, format := range files {
+		file, err := os.Open(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, name, err := image.Decode(file)
+		file.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name != format {
+			t.Errorf("%s: wrong format %s", filename, name)
+		}
+		if img.Bounds() != image.Rect(0, 0, imageWidth, imageHeight) {
+			t.Errorf("%s: wrong bounds %v", filename, img.Bounds())
+		}
+	}
+}
+
+func TestImageDecodeConfig(t *testing.T) {
+	file, err := os.Open("./testImages/pgm/testP5.pgm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	config, name, err := image.DecodeConfig(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "pgm" || config.Width != imagePGMWidth || config.Height != imagePGMHeight || config.ColorModel != color.GrayModel {
+		t.Errorf("Wrong config %s %+v", name, config)
+	}
+}
+
+func TestImageAt(t *testing.T) {
+	pbm, err := ReadPBM("./testImages/pbm/testP1.pbm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pbm.Image().At(0, 8) != (color.Gray{Y: 0}) || pbm.Image().At(0, 0) != (color.Gray{Y: 255}) {
+		t.Error("Wrong PBM color")
+	}
+
+	pgm, err := ReadPGM("./testImages/pgm/testP2.pgm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pgm.Image().At(0, 0) != (color.Gray{Y: 255}) || pgm.Image().At(0, 8) != (color.Gray{Y: 0}) {
+		t.Error("Wrong PGM color")
+	}
+	pgm.max = 1023
+	pgm.Set(0, 0, 1023)
+	if pgm.Image().At(0, 0) != (color.Gray16{Y: 65535}) {
+		t.Error("Wrong 16-bit PGM color")
+	}
+
+	ppm, err := ReadPPM("./testImages/ppm/testP3.ppm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ppm.Image().At(1, 3) != (color.RGBA{255, 255, 255, 255}) || ppm.Image().At(9, 3) != (color.RGBA{255, 255, 0, 255}) {
+		t.Error("Wrong PPM color")
+	}
+}
+
+func TestImageDraw(t *testing.T) {
+	ppm, err := ReadPPM("./testImages/ppm/testP3.ppm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rgba := image.NewRGBA(image.Rect(0, 0, imagePPMWidth, imagePPMHeight))
+	draw.Draw(rgba, rgba.Bounds(), ppm.Image(), image.Point{}, draw.Src)
+	clone := NewPPMFromImage(rgba)
+	for i := 0; i < imageWidth*imageHeight; i++ {
+		x := i % imageWidth
+		y := i / imageWidth
+		if clone.At(x, y) != imagePPMData[i] {
+			t.Errorf("Pixel at (%d, %d) not copied correctly", x, y)
+		}
+	}
+
+	draw.Draw(ppm.Image(), image.Rect(0, 0, 2, 2), image.NewUniform(color.RGBA{1, 2, 3, 255}), image.Point{}, draw.Src)
+	if ppm.At(1, 1) != (Pixel{1, 2, 3}) || ppm.At(2, 2) != imagePPMData[2*imageWidth+2] {
+		t.Error("Pixels not drawn correctly")
+	}
+}
+
+func TestNewFromImage(t *testing.T) {
+	gray := image.NewGray16(image.Rect(2, 2, 4, 3))
+	gray.SetGray16(2, 2, color.Gray16{Y: 1000})
+	gray.SetGray16(3, 2, color.Gray16{Y: 65535})
+
+	pgm := NewPGMFromImage(gray)
+	if pgm.width != 2 || pgm.height != 1 || pgm.max != 65535 {
+		t.Error("Wrong PGM size or max value")
+	}
+	if pgm.At(0, 0) != 1000 || pgm.At(1, 0) != 65535 {
+		t.Error("Wrong PGM data")
+	}
+
+	pbm := NewPBMFromImage(gray)
+	if !pbm.At(0, 0) || pbm.At(1, 0) {
+		t.Error("Wrong PBM data")
+	}
+}
@@ -0,0 +1,155 @@
+package Netpbm
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+	"io/fs"
+	"os"
+)
+
+/*
+Image is what Decode returns and what *PBM, *PGM, *PPM and *PAM have in
+common. Format is the magic number; MaxValue is 1 for bitmaps. Clone
+returns the same concrete type. Use a type switch to reach the pixels, or
+ToPBM, ToPGM and ToPPM to convert from any of them.
+*/
+type Image interface {
+	Size() (int, int)
+	Bounds() image.Rectangle
+	Format() string
+	MaxValue() uint16
+	Clone() Image
+	Invert()
+	Flip()
+	Flop()
+	Rotate90CW()
+	SetMagicNumber(magicNumber string)
+	Save(filename string, opts ...SaveOption) error
+	Encode(w io.Writer) error
+}
+
+/*drawable is implemented by every Image of this package, see PBM.Image.*/
+type drawable interface {
+	Image() draw.Image
+}
+
+// ToPBM converts any Image to a bitmap, pixels darker than half max becoming
+// set (black) whichever path is taken; a *PBM is cloned. It returns nil for
+// an Image of another package without an Image() draw.Image method.
+func ToPBM(img Image) *PBM {
+	switch img := img.(type) {
+	case *PBM:
+		return img.Clone().(*PBM)
+	case *PGM:
+		return img.ToPBM()
+	case *PPM:
+		return img.ToPBM()
+	case *PAM:
+		return img.ToPBM()
+	case drawable:
+		return NewPBMFromImage(img.Image())
+	}
+	return nil
+}
+
+// ToPGM converts any Image to a graymap, see ToPBM.
+func ToPGM(img Image) *PGM {
+	switch img := img.(type) {
+	case *PBM:
+		return img.ToPGM()
+	case *PGM:
+		return img.Clone().(*PGM)
+	case *PPM:
+		return img.ToPGM()
+	case *PAM:
+		return img.ToPGM()
+	case drawable:
+		return NewPGMFromImage(img.Image())
+	}
+	return nil
+}
+
+// ToPPM converts any Image to a pixmap, see ToPBM.
+func ToPPM(img Image) *PPM {
+	switch img := img.(type) {
+	case *PBM:
+		return img.ToPPM()
+	case *PGM:
+		return img.ToPPM()
+	case *PPM:
+		return img.Clone().(*PPM)
+	case *PAM:
+		return img.ToPPM()
+	case drawable:
+		return NewPPMFromImage(img.Image())
+	}
+	return nil
+}
+
+// UnknownFormatError is returned by Decode when the magic number names no supported format.
+type UnknownFormatError struct {
+	MagicNumber string
+}
+
+func (e *UnknownFormatError) Error() string {
+	return fmt.Sprintf("unknown Netpbm magic number: %q", e.MagicNumber)
+}
+
+func (e *UnknownFormatError) Unwrap() error {
+	return ErrBadMagic
+}
+
+// Read opens a Netpbm file of any supported type, see Decode.
+func Read(filename string, opts ...DecodeOption) (Image, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return Decode(file, fileOptions(filename, opts)...)
+}
+
+// ReadFS is Read for a file of fsys, such as an embed.FS.
+func ReadFS(fsys fs.FS, name string, opts ...DecodeOption) (Image, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return Decode(file, fileOptions(name, opts)...)
+}
+
+// Decode reads the magic number from r and decodes the image it names.
+func Decode(r io.Reader, opts ...DecodeOption) (Image, error) {
+	tok := newTokenizer(r, opts...)
+	magicNumber, err := tok.nextMagic()
+	if err != nil {
+		return nil, err
+	}
+	return decodeImage(tok, magicNumber)
+}
+
+/*decodeImage dispatches on a magic number already read from tok.*/
+func decodeImage(tok *tokenizer, magicNumber string) (Image, error) {
+	/*A typed nil would not compare equal to nil, so errors are returned explicitly*/
+	var img Image
+	var err error
+	switch magicNumber {
+	case "P1", "P4":
+		img, err = decodePBM(tok, magicNumber)
+	case "P2", "P5":
+		img, err = decodePGM(tok, magicNumber)
+	case "P3", "P6":
+		img, err = decodePPM(tok, magicNumber)
+	case "P7":
+		img, err = decodePAM(tok, magicNumber)
+	default:
+		return nil, &UnknownFormatError{MagicNumber: magicNumber}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return img, nil
+}
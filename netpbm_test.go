@@ -0,0 +1,148 @@
+package Netpbm
+
+import (
+	"errors"
+	"image"
+	"strings"
+	"testing"
+)
+
+func TestRead(t *testing.T) {
+	files := map[string]string{
+		"./testImages/pbm/testP1.pbm": "P1",
+		"./testImages/pbm/testP4.pbm": "P4",
+		"./testImages/pgm/testP2.pgm": "P2",
+		"./testImages/pgm/testP5.pgm": "P5",
+		"./testImages/ppm/testP3.ppm": "P3",
+		"./testImages/ppm/testP6.ppm": "P6",
+	}
+	for filename, magicNumber := range files {
+		img, err := Read(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch img := img.(type) {
+		case *PBM:
+			if img.magicNumber != magicNumber {
+				t.Errorf("%s: wrong magic number %s", filename, img.magicNumber)
+			}
+		case *PGM:
+			if img.magicNumber != magicNumber {
+				t.Errorf("%s: wrong magic number %s", filename, img.magicNumber)
+			}
+		case *PPM:
+			if img.magicNumber != magicNumber {
+				t.Errorf("%s: wrong magic number %s", filename, img.magicNumber)
+			}
+		default:
+			t.Errorf("%s: unexpected type %T", filename, img)
+		}
+		w, h := img.Size()
+		if w != imageWidth || h != imageHeight {
+			t.Errorf("%s: wrong size", filename)
+		}
+	}
+}
+
+func TestDecodeUnknownFormat(t *testing.T) {
+	img, err := Decode(strings.NewReader("GIF89a"))
+	if img != nil {
+		t.Error("Expected no image")
+	}
+	var formatErr *UnknownFormatError
+	if !errors.As(err, &formatErr) || formatErr.MagicNumber != "GIF89a" {
+		t.Errorf("Expected an UnknownFormatError, got %v", err)
+	}
+
+	img, err = Decode(strings.NewReader("P2\n2 2\n"))
+	if img != nil || err == nil {
+		t.Error("Expected an error for a truncated image")
+	}
+}
+
+func TestImageInterface(t *testing.T) {
+	inputs := []string{
+		"P1\n3 2\n100\n001\n",
+		"P2\n3 2\n9\n1 2 3\n4 5 6\n",
+		"P3\n3 2\n9\n1 1 1 2 2 2 3 3 3\n4 4 4 5 5 5 6 6 6\n",
+		"P7\nWIDTH 3\nHEIGHT 2\nDEPTH 1\nMAXVAL 9\nTUPLTYPE GRAYSCALE\nENDHDR\n\x01\x02\x03\x04\x05\x06",
+	}
+	for _, input := range inputs {
+		img, err := Decode(strings.NewReader(input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if img.Format() != input[:2] || img.Bounds() != image.Rect(0, 0, 3, 2) {
+			t.Errorf("%s: wrong format %s or bounds %v", input[:2], img.Format(), img.Bounds())
+		}
+		if max := img.MaxValue(); (input[:2] == "P1") != (max == 1) {
+			t.Errorf("%s: wrong max value %d", input[:2], max)
+		}
+
+		clone := img.Clone()
+		clone.Rotate90CW()
+		if width, height := clone.Size(); width != 2 || height != 3 {
+			t.Errorf("%s: rotated clone is %dx%d", input[:2], width, height)
+		}
+		if width, _ := img.Size(); width != 3 {
+			t.Errorf("%s: rotating the clone changed the original", input[:2])
+		}
+
+		/*Top left goes to top right, whatever the format*/
+		pgm := ToPGM(img)
+		rotated := ToPGM(clone)
+		if pgm == nil || rotated == nil || rotated.At(1, 0) != pgm.At(0, 0) || rotated.At(0, 2) != pgm.At(2, 1) {
+			t.Errorf("%s: wrong rotation", input[:2])
+		}
+		if ToPBM(img) == nil || ToPPM(img) == nil {
+			t.Errorf("%s: conversion failed", input[:2])
+		}
+	}
+}
+
+func TestConvertImage(t *testing.T) {
+	pbm := NewPBM(2, 1)
+	pbm.Set(0, 0, true)
+	pbm.AddComment("bitmap")
+	ppm := ToPPM(pbm)
+	if ppm.At(0, 0) != (Pixel{0, 0, 0}) || ppm.At(1, 0) != (Pixel{1, 1, 1}) || ppm.MaxValue() != 1 {
+		t.Errorf("Wrong pixmap %v", ppm.Pix)
+	}
+	pgm := ToPGM(pbm)
+	if pgm.At(0, 0) != 0 || pgm.At(1, 0) != 1 || len(pgm.Comments) != 1 {
+		t.Errorf("Wrong graymap %v %q", pgm.Pix, pgm.Comments)
+	}
+
+	same := ToPBM(pbm)
+	same.Set(1, 0, true)
+	if pbm.At(1, 0) {
+		t.Error("ToPBM of a *PBM must copy it")
+	}
+
+	for _, back := range []*PBM{ToPBM(ToPGM(pbm)), ToPBM(ToPPM(pbm)), ToPBM(pbm.ToPAM())} {
+		if !back.At(0, 0) || back.At(1, 0) {
+			t.Errorf("Round trip changed the bitmap: %v", back.Pix)
+		}
+	}
+}
+
+func TestConvertImagePaths(t *testing.T) {
+	/*Every path to a bitmap sets the samples darker than half max*/
+	ramp := NewPGM(11, 1, 10)
+	for x := 0; x < 11; x++ {
+		ramp.Set(x, 0, uint16(x))
+	}
+	paths := map[string]*PBM{
+		"ToPBM":           ToPBM(ramp),
+		"ToPAM":           ToPBM(ramp.ToPAM()),
+		"ToPPM":           ToPBM(ramp.ToPPM()),
+		"NewPBMFromImage": NewPBMFromImage(ramp.Image()),
+	}
+	for name, pbm := range paths {
+		for x := 0; x < 11; x++ {
+			if pbm.At(x, 0) != (x < 5) {
+				t.Errorf("%s: pixel %d is %t", name, x, pbm.At(x, 0))
+			}
+		}
+	}
+}
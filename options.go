@@ -0,0 +1,152 @@
+package Netpbm
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+/*
+DecodeOption tunes the Decode and Read functions. By default an image may
+be up to 1<<20 pixels wide and high, hold 1<<28 pixels in all and take
+1<<30 bytes of memory once decoded; a header beyond any limit is rejected
+before the raster is allocated.
+*/
+type DecodeOption func(*decodeOptions)
+
+type decodeOptions struct {
+	maxWidth, maxHeight int64
+	maxPixels, maxBytes int64
+	mode                ParseMode
+	warnings            *[]*ParseError
+	filename            string /*set by the Read functions for ParseError.File*/
+	decompress          bool
+}
+
+const (
+	defaultMaxWidth  = 1 << 20
+	defaultMaxHeight = 1 << 20
+	defaultMaxPixels = 1 << 28
+	defaultMaxBytes  = 1 << 30
+)
+
+func newDecodeOptions(opts []DecodeOption) decodeOptions {
+	options := decodeOptions{
+		maxWidth:  defaultMaxWidth,
+		maxHeight: defaultMaxHeight,
+		maxPixels: defaultMaxPixels,
+		maxBytes:  defaultMaxBytes,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+// WithMaxWidth limits the width in pixels; 0 or less removes the limit.
+func WithMaxWidth(n int) DecodeOption {
+	return func(o *decodeOptions) { o.maxWidth = int64(n) }
+}
+
+// WithMaxHeight limits the height in pixels; 0 or less removes the limit.
+func WithMaxHeight(n int) DecodeOption {
+	return func(o *decodeOptions) { o.maxHeight = int64(n) }
+}
+
+// WithMaxPixels limits width*height; 0 or less removes the limit.
+func WithMaxPixels(n int64) DecodeOption {
+	return func(o *decodeOptions) { o.maxPixels = n }
+}
+
+// WithMaxBytes limits the memory taken by the decoded raster; 0 or less removes the limit.
+func WithMaxBytes(n int64) DecodeOption {
+	return func(o *decodeOptions) { o.maxBytes = n }
+}
+
+/*
+ParseMode says what a decoder does with input that breaks the spec. Strict,
+the default, rejects it: samples above the max value, a sample count that
+is not exact, anything but 0 and 1 in P1, and a max value outside 1..65535.
+Lenient pads truncated rasters with zeros, clamps samples and max values
+that are too large, and ignores extra samples; what it recovered from can
+be collected with WithWarnings.
+*/
+type ParseMode int
+
+const (
+	Strict ParseMode = iota
+	Lenient
+)
+
+// WithMode selects Strict or Lenient parsing.
+func WithMode(mode ParseMode) DecodeOption {
+	return func(o *decodeOptions) { o.mode = mode }
+}
+
+// WithWarnings appends to warnings the first problem of each kind that Lenient mode recovered from in each image.
+func WithWarnings(warnings *[]*ParseError) DecodeOption {
+	return func(o *decodeOptions) { o.warnings = warnings }
+}
+
+// WithDecompression makes the decoder look at the first bytes of the input
+// and decompress it first when it is gzip or bzip2. The Read functions
+// always do this.
+func WithDecompression() DecodeOption {
+	return func(o *decodeOptions) { o.decompress = true }
+}
+
+func withFilename(filename string) DecodeOption {
+	return func(o *decodeOptions) { o.filename = filename }
+}
+
+/*fileOptions are the options of the Read functions, opts coming last.*/
+func fileOptions(filename string, opts []DecodeOption) []DecodeOption {
+	return append([]DecodeOption{withFilename(filename), WithDecompression()}, opts...)
+}
+
+// ErrLimitExceeded is wrapped by every LimitError.
+var ErrLimitExceeded = errors.New("limit exceeded")
+
+// LimitError reports a header that goes beyond one of the decoder limits.
+type LimitError struct {
+	Limit string /*"width", "height", "pixels" or "bytes"*/
+	Value int64  /*math.MaxInt64 when the product overflows*/
+	Max   int64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s %d exceeds the limit of %d", e.Limit, e.Value, e.Max)
+}
+
+func (e *LimitError) Unwrap() error {
+	return ErrLimitExceeded
+}
+
+/*multiply saturates at math.MaxInt64 instead of overflowing, a and b are not negative.*/
+func multiply(a, b int64) int64 {
+	if a != 0 && b > math.MaxInt64/a {
+		return math.MaxInt64
+	}
+	return a * b
+}
+
+/*
+checkSize compares a decoded header with the limits, bytesPerPixel being
+the memory one pixel takes once decoded. The error points at the last token.
+*/
+func (t *tokenizer) checkSize(width, height int, bytesPerPixel int64) error {
+	pixels := multiply(int64(width), int64(height))
+	checks := []LimitError{
+		{"width", int64(width), t.options.maxWidth},
+		{"height", int64(height), t.options.maxHeight},
+		{"pixels", pixels, t.options.maxPixels},
+		{"bytes", multiply(pixels, bytesPerPixel), t.options.maxBytes},
+	}
+	for _, check := range checks {
+		if check.Max > 0 && check.Value > check.Max {
+			limitErr := check
+			return t.tokenError(fmt.Sprintf("%s at most %d", check.Limit, check.Max), fmt.Sprintf("%d", check.Value), &limitErr)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package Netpbm
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDecodeLimits(t *testing.T) {
+	hostile := "P5\n2000000000 2000000000\n255\n"
+	_, err := DecodePGM(strings.NewReader(hostile))
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("Expected a LimitError, got %v", err)
+	}
+	if limitErr.Limit != "width" || limitErr.Value != 2000000000 || limitErr.Max != 1<<20 {
+		t.Errorf("Wrong limit %+v", limitErr)
+	}
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || parseErr.Line != 2 || parseErr.Column != 12 {
+		t.Errorf("Limit error should point at the height, got %+v", parseErr)
+	}
+
+	cases := []struct {
+		input string
+		opts  []DecodeOption
+		limit string
+	}{
+		{"P1\n4 5\n", []DecodeOption{WithMaxHeight(4)}, "height"},
+		{"P2\n4 4\n255\n", []DecodeOption{WithMaxPixels(15)}, "pixels"},
+		{"P6\n4 4\n255\n", []DecodeOption{WithMaxBytes(95)}, "bytes"},
+		{"P7\nWIDTH 2\nHEIGHT 2\nDEPTH 4\nMAXVAL 255\nENDHDR\n", []DecodeOption{WithMaxBytes(31)}, "bytes"},
+		{"P7\nWIDTH 1\nHEIGHT 1\nDEPTH 4611686018427387904\nMAXVAL 255\nENDHDR\n", nil, "bytes"},
+	}
+	for _, c := range cases {
+		_, err := Decode(strings.NewReader(c.input), c.opts...)
+		if !errors.As(err, &limitErr) || limitErr.Limit != c.limit {
+			t.Errorf("%q: expected the %s limit, got %v", c.input, c.limit, err)
+		}
+	}
+}
+
+func TestDecodeLimitsDisabled(t *testing.T) {
+	/*With the bytes limit just met the image decodes*/
+	pgm, err := DecodePGM(strings.NewReader("P2\n2 2\n255\n1 2 3 4\n"), WithMaxBytes(8), WithMaxPixels(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pgm.At(1, 1) != 4 {
+		t.Error("Image not decoded")
+	}
+
+	dec := NewStreamDecoder(strings.NewReader("P1\n1 1\n0\nP1\n3 1\n000\n"), WithMaxWidth(2))
+	for dec.Next() {
+	}
+	if dec.Count() != 1 || !errors.Is(dec.Err(), ErrLimitExceeded) {
+		t.Errorf("Stream limits not applied: %d images, %v", dec.Count(), dec.Err())
+	}
+
+	if _, err := NewRowReader(strings.NewReader("P5\n40000 40000\n255\n")); err != nil {
+		t.Errorf("Row reader should only limit one row: %v", err)
+	}
+}
+
+func TestStrictMode(t *testing.T) {
+	cases := []struct {
+		input string
+		err   error
+	}{
+		{"P2\n2 1\n255\n1 2 3\n", ErrSyntax},
+		{"P2\n2 1\n255\n1\n", ErrTruncated},
+		{"P2\n2 1\n10\n1 11\n", ErrSampleOutOfRange},
+		{"P5\n2 1\n10\n\x01\x0b", ErrSampleOutOfRange},
+		{"P1\n2 1\n0 2\n", ErrSampleOutOfRange},
+		{"P1\n2 1\n0 1 1\n", ErrSyntax},
+		{"P2\n2 1\n70000\n1 2\n", ErrBadMaxValue},
+		{"P2\n2 1\n0\n0 0\n", ErrBadMaxValue},
+	}
+	for _, c := range cases {
+		if _, err := Decode(strings.NewReader(c.input)); !errors.Is(err, c.err) {
+			t.Errorf("%q: expected %v, got %v", c.input, c.err, err)
+		}
+	}
+}
+
+func TestTooManySamples(t *testing.T) {
+	/*Found counts every sample, and P1 bits need no separator*/
+	cases := map[string]string{
+		"P1\n2 1\n0 1 1\n":        "3",
+		"P1\n2 1\n01 101\n":       "5",
+		"P2\n2 1\n255\n1 2 3 4\n": "4",
+	}
+	for input, found := range cases {
+		var parseErr *ParseError
+		if _, err := Decode(strings.NewReader(input)); !errors.As(err, &parseErr) || parseErr.Expected != "2 samples" || parseErr.Found != found {
+			t.Errorf("%q: expected %s samples found, got %v", input, found, err)
+		}
+	}
+}
+
+func TestLenientMode(t *testing.T) {
+	var warnings []*ParseError
+	pgm, err := DecodePGM(strings.NewReader("P2\n3 2\n10\n1 12 3\n4\n"), WithMode(Lenient), WithWarnings(&warnings))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pgm.At(1, 0) != 10 || pgm.At(0, 1) != 4 || pgm.At(2, 1) != 0 {
+		t.Errorf("Samples not clamped and padded: %v", pgm.Pix)
+	}
+	if len(warnings) != 2 || !errors.Is(warnings[0], ErrSampleOutOfRange) || !errors.Is(warnings[1], ErrTruncated) {
+		t.Errorf("Wrong warnings %v", warnings)
+	}
+
+	ppm, err := DecodePPM(strings.NewReader("P6\n2 1\n100\n\x01\x02\xff\x04"), WithMode(Lenient))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ppm.At(0, 0) != (Pixel{1, 2, 100}) || ppm.At(1, 0) != (Pixel{4, 0, 0}) {
+		t.Errorf("Raw samples not clamped and padded: %v", ppm.Pix)
+	}
+
+	pbm, err := DecodePBM(strings.NewReader("P1\n3 1\n0 5\n"), WithMode(Lenient))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pbm.At(0, 0) || !pbm.At(1, 0) || pbm.At(2, 0) {
+		t.Errorf("Wrong bits %v", pbm.Pix)
+	}
+
+	warnings = nil
+	pgm, err = DecodePGM(strings.NewReader("P2\n1 1\n70000\n65535 7\n"), WithMode(Lenient), WithWarnings(&warnings))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pgm.max != 65535 || pgm.At(0, 0) != 65535 {
+		t.Errorf("Max value not clamped: %d", pgm.max)
+	}
+	if len(warnings) != 2 || !errors.Is(warnings[0], ErrBadMaxValue) || !errors.Is(warnings[1], ErrSyntax) {
+		t.Errorf("Wrong warnings %v", warnings)
+	}
+
+	/*A max value of zero leaves nothing to clamp to*/
+	if _, err := DecodePGM(strings.NewReader("P2\n1 1\n0\n0\n"), WithMode(Lenient)); !errors.Is(err, ErrBadMaxValue) {
+		t.Errorf("Expected ErrBadMaxValue, got %v", err)
+	}
+
+	/*PAM clamps its MAXVAL the same way*/
+	warnings = nil
+	hugePAM := "P7\nWIDTH 1\nHEIGHT 1\nDEPTH 1\nMAXVAL 70000\nENDHDR\n\xff\xfe"
+	pam, err := DecodePAM(strings.NewReader(hugePAM), WithMode(Lenient), WithWarnings(&warnings))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pam.MaxValue() != 65535 || pam.At(0, 0)[0] != 0xfffe || len(warnings) != 1 || !errors.Is(warnings[0], ErrBadMaxValue) {
+		t.Errorf("PAM MAXVAL not clamped: %d, %v", pam.MaxValue(), warnings)
+	}
+	if _, err := DecodePAM(strings.NewReader(hugePAM)); !errors.Is(err, ErrBadMaxValue) {
+		t.Errorf("Expected ErrBadMaxValue in Strict mode, got %v", err)
+	}
+}
+
+func TestLenientWarningsFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "short.pgm")
+	if err := os.WriteFile(filename, []byte("P5\n2 2\n255\n\x01"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var warnings []*ParseError
+	if _, err := ReadPGM(filename, WithMode(Lenient), WithWarnings(&warnings)); err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 1 || warnings[0].File != filename || warnings[0].Offset != 12 {
+		t.Errorf("Wrong warnings %v", warnings)
+	}
+
+	/*Each image of a stream gets its own warnings*/
+	warnings = nil
+	dec := NewStreamDecoder(strings.NewReader("P2\n1 1\n1\n2\nP2\n1 1\n1\n3\n"), WithMode(Lenient), WithWarnings(&warnings))
+	for dec.Next() {
+	}
+	if dec.Err() != nil || dec.Count() != 2 || len(warnings) != 2 {
+		t.Errorf("Wrong stream warnings: %v, %v", dec.Err(), warnings)
+	}
+}
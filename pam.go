@@ -0,0 +1,531 @@
+package Netpbm
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+)
+
+/*
+PAM is a P7 image: every pixel is a tuple of depth samples. The tuple type
+names what the samples mean, for instance GRAYSCALE, RGB or RGB_ALPHA; an
+_ALPHA suffix means the last sample is the opacity.
+*/
+type PAM struct {
+	data          [][]uint16 /*each row holds width*depth samples, one tuple after the other*/
+	width, height int
+	depth         int
+	max           uint16
+	tupleType     string
+	magicNumber   string
+}
+
+// NewPAM returns an image of the given shape with every sample set to 0; a max value of 0 is taken as 1.
+func NewPAM(width, height, depth int, max uint16, tupleType string) *PAM {
+	pam := &PAM{
+		data:        make([][]uint16, height),
+		width:       width,
+		height:      height,
+		depth:       depth,
+		max:         validMax(max),
+		tupleType:   tupleType,
+		magicNumber: "P7",
+	}
+	for y := range pam.data {
+		pam.data[y] = make([]uint16, width*depth)
+	}
+	return pam
+}
+
+func ReadPAM(filename string, opts ...DecodeOption) (*PAM, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return DecodePAM(file, fileOptions(filename, opts)...)
+}
+
+// ReadPAMFS is ReadPAM for a file of fsys, such as an embed.FS.
+func ReadPAMFS(fsys fs.FS, name string, opts ...DecodeOption) (*PAM, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return DecodePAM(file, fileOptions(name, opts)...)
+}
+
+// DecodePAM reads a P7 image from r.
+func DecodePAM(r io.Reader, opts ...DecodeOption) (*PAM, error) {
+	tok := newTokenizer(r, opts...)
+	magicNumber, err := tok.nextMagic()
+	if err != nil {
+		return nil, err
+	}
+	if magicNumber != "P7" {
+		return nil, tok.tokenError("P7", fmt.Sprintf("%q", magicNumber), ErrBadMagic)
+	}
+	return decodePAM(tok, magicNumber)
+}
+
+/*decodePAM reads the header, then the raw tuples.*/
+func decodePAM(tok *tokenizer, magicNumber string) (*PAM, error) {
+	pam, err := decodePAMHeader(tok, magicNumber)
+	if err != nil {
+		return nil, err
+	}
+	if err := tok.checkSize(pam.width, pam.height, multiply(2, int64(pam.depth))); err != nil {
+		return nil, err
+	}
+
+	/*Samples are one byte, or two bytes big-endian when max is above 255*/
+	size := sampleSize(pam.max)
+	buffer := make([]byte, pam.width*pam.depth*size)
+	pam.data = make([][]uint16, pam.height)
+	for i := range pam.data {
+		if err := tok.readFull(buffer, fmt.Sprintf("row %d", i+1)); err != nil {
+			return nil, err
+		}
+		pam.data[i] = make([]uint16, pam.width*pam.depth)
+		for j := range pam.data[i] {
+			if size == 2 {
+				pam.data[i][j] = binary.BigEndian.Uint16(buffer[j*2:])
+			} else {
+				pam.data[i][j] = uint16(buffer[j])
+			}
+			if pam.data[i][j], err = tok.checkRaw(len(buffer)-j*size, pam.data[i][j], pam.max); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return pam, nil
+}
+
+/*decodePAMHeader reads the line oriented header up to ENDHDR.*/
+func decodePAMHeader(tok *tokenizer, magicNumber string) (*PAM, error) {
+	pam := &PAM{magicNumber: magicNumber}
+	max := 0
+	for {
+		line, err := tok.nextLine()
+		if err != nil {
+			return nil, tok.endError("ENDHDR", err)
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			tok.comments = append(tok.comments, strings.TrimSpace(line[1:]))
+			continue
+		}
+		fields := strings.Fields(line)
+		if fields[0] == "ENDHDR" {
+			break
+		}
+		if fields[0] == "TUPLTYPE" {
+			/*Several TUPLTYPE lines are joined with a space*/
+			value := strings.TrimSpace(line[len("TUPLTYPE"):])
+			if pam.tupleType != "" {
+				value = pam.tupleType + " " + value
+			}
+			pam.tupleType = value
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, tok.tokenError("header line", fmt.Sprintf("%q", line), ErrSyntax)
+		}
+		value, err := parseDigits(fields[1])
+		if err != nil {
+			return nil, tok.tokenError("number", fmt.Sprintf("%q", line), ErrSyntax)
+		}
+		switch fields[0] {
+		case "WIDTH":
+			pam.width = value
+		case "HEIGHT":
+			pam.height = value
+		case "DEPTH":
+			pam.depth = value
+		case "MAXVAL":
+			max = value
+		default:
+			return nil, tok.tokenError("header line", fmt.Sprintf("%q", line), ErrSyntax)
+		}
+	}
+	/*Missing or bad header values are reported at ENDHDR*/
+	if pam.width <= 0 || pam.height <= 0 || pam.depth <= 0 {
+		return nil, tok.tokenError("positive WIDTH, HEIGHT and DEPTH", fmt.Sprintf("%d x %d x %d", pam.width, pam.height, pam.depth), ErrBadDimensions)
+	}
+	if max < 1 || max > 65535 {
+		problem := tok.tokenError("MAXVAL from 1 to 65535", strconv.Itoa(max), ErrBadMaxValue)
+		/*Like nextMaxValue, Lenient mode clamps a MAXVAL that is too large, not a missing or zero one*/
+		if max < 1 || !tok.tolerate(problem) {
+			return nil, problem
+		}
+		max = 65535
+	}
+	pam.max = uint16(max)
+	return pam, nil
+}
+
+func (pam *PAM) Size() (int, int) {
+	return pam.width, pam.height
+}
+
+func (pam *PAM) Bounds() image.Rectangle {
+	return image.Rect(0, 0, pam.width, pam.height)
+}
+
+// Format returns the magic number, P7.
+func (pam *PAM) Format() string {
+	return pam.magicNumber
+}
+
+func (pam *PAM) MaxValue() uint16 {
+	return pam.max
+}
+
+// Clone returns a deep copy of the image as a *PAM.
+func (pam *PAM) Clone() Image {
+	clone := *pam
+	clone.data = make([][]uint16, pam.height)
+	for y, row := range pam.data {
+		clone.data[y] = append([]uint16(nil), row...)
+	}
+	return &clone
+}
+
+func (pam *PAM) Depth() int {
+	return pam.depth
+}
+
+func (pam *PAM) TupleType() string {
+	return pam.tupleType
+}
+
+/*HasAlpha reports whether the last sample of each tuple is an opacity.*/
+func (pam *PAM) HasAlpha() bool {
+	return strings.HasSuffix(pam.tupleType, "_ALPHA") && pam.depth > 1
+}
+
+/*colors is the number of samples per tuple that are not alpha.*/
+func (pam *PAM) colors() int {
+	if pam.HasAlpha() {
+		return pam.depth - 1
+	}
+	return pam.depth
+}
+
+// At returns a copy of the tuple at (x, y), all zeros outside the bounds like Raster.At.
+func (pam *PAM) At(x, y int) []uint16 {
+	tuple := make([]uint16, pam.depth)
+	if x < 0 || y < 0 || x >= pam.width || y >= pam.height {
+		return tuple
+	}
+	copy(tuple, pam.data[y][x*pam.depth:(x+1)*pam.depth])
+	return tuple
+}
+
+// Set does nothing outside the bounds, like Raster.Set.
+func (pam *PAM) Set(x, y int, tuple []uint16) {
+	if x < 0 || y < 0 || x >= pam.width || y >= pam.height {
+		return
+	}
+	copy(pam.data[y][x*pam.depth:(x+1)*pam.depth], tuple)
+}
+
+func (pam *PAM) Save(filename string, opts ...SaveOption) error {
+	return saveFile(filename, pam.Encode, opts)
+}
+
+// Encode writes the image to w; P7 has no plain variant.
+func (pam *PAM) Encode(w io.Writer) error {
+	if pam.magicNumber != "P7" {
+		return fmt.Errorf("unsupported PAM magic number: %s", pam.magicNumber)
+	}
+	writer := bufio.NewWriter(w)
+
+	fmt.Fprintf(writer, "P7\nWIDTH %d\nHEIGHT %d\nDEPTH %d\nMAXVAL %d\n", pam.width, pam.height, pam.depth, pam.max)
+	if pam.tupleType != "" {
+		fmt.Fprintf(writer, "TUPLTYPE %s\n", pam.tupleType)
+	}
+	fmt.Fprint(writer, "ENDHDR\n")
+
+	size := sampleSize(pam.max)
+	buffer := make([]byte, pam.width*pam.depth*size)
+	for _, row := range pam.data {
+		for j, value := range row {
+			if size == 2 {
+				binary.BigEndian.PutUint16(buffer[j*2:], value)
+			} else {
+				buffer[j] = uint8(value)
+			}
+		}
+		if _, err := writer.Write(buffer); err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
+
+// Invert inverts every sample except the alpha channel.
+func (pam *PAM) Invert() {
+	colors := pam.colors()
+	for y := 0; y < pam.height; y++ {
+		for x := 0; x < pam.width; x++ {
+			for k := 0; k < colors; k++ {
+				pam.data[y][x*pam.depth+k] = pam.max - pam.data[y][x*pam.depth+k]
+			}
+		}
+	}
+}
+
+func (pam *PAM) Flip() {
+	for y := 0; y < pam.height; y++ {
+		for x := 0; x < pam.width/2; x++ {
+			left := pam.data[y][x*pam.depth : (x+1)*pam.depth]
+			right := pam.data[y][(pam.width-x-1)*pam.depth : (pam.width-x)*pam.depth]
+			for k := range left {
+				left[k], right[k] = right[k], left[k]
+			}
+		}
+	}
+}
+
+func (pam *PAM) Flop() {
+	for y := 0; y < pam.height/2; y++ {
+		pam.data[y], pam.data[pam.height-y-1] = pam.data[pam.height-y-1], pam.data[y]
+	}
+}
+
+func (pam *PAM) Rotate90CW() {
+	/*Source row y becomes destination column height-y-1, tuple by tuple*/
+	rotated := make([][]uint16, pam.width)
+	for x := range rotated {
+		rotated[x] = make([]uint16, pam.height*pam.depth)
+	}
+	for y, row := range pam.data {
+		column := (pam.height - y - 1) * pam.depth
+		for x := 0; x < pam.width; x++ {
+			copy(rotated[x][column:column+pam.depth], row[x*pam.depth:(x+1)*pam.depth])
+		}
+	}
+
+	pam.data = rotated
+	pam.width, pam.height = pam.height, pam.width
+}
+
+func (pam *PAM) SetMagicNumber(magicNumber string) {
+	pam.magicNumber = magicNumber
+}
+
+/*
+AddAlpha appends a fully opaque alpha channel, turning for instance RGB into
+RGB_ALPHA. It does nothing if the image already has one.
+*/
+func (pam *PAM) AddAlpha() {
+	if pam.HasAlpha() {
+		return
+	}
+	for y := range pam.data {
+		row := make([]uint16, pam.width*(pam.depth+1))
+		for x := 0; x < pam.width; x++ {
+			copy(row[x*(pam.depth+1):], pam.data[y][x*pam.depth:(x+1)*pam.depth])
+			row[x*(pam.depth+1)+pam.depth] = pam.max
+		}
+		pam.data[y] = row
+	}
+	pam.depth++
+	pam.tupleType += "_ALPHA"
+}
+
+/*gray returns the gray level of the tuple at (x, y), the alpha channel is dropped.*/
+func (pam *PAM) gray(x, y int) uint16 {
+	tuple := pam.data[y][x*pam.depth : (x+1)*pam.depth]
+	if pam.colors() >= 3 {
+		return uint16((uint32(tuple[0]) + uint32(tuple[1]) + uint32(tuple[2])) / 3)
+	}
+	return tuple[0]
+}
+
+/*
+ToPBM follows the BLACKANDWHITE convention of PAM: a sample of 0 is black,
+so tuples darker than half max become set pixels.
+*/
+func (pam *PAM) ToPBM() *PBM {
+	pbm := NewPBM(pam.width, pam.height)
+	for y := 0; y < pam.height; y++ {
+		row := pbm.Row(y)
+		for x := range row {
+			row[x] = uint32(pam.gray(x, y))*2 < uint32(pam.max)
+		}
+	}
+	return pbm
+}
+
+func (pam *PAM) ToPGM() *PGM {
+	pgm := NewPGM(pam.width, pam.height, pam.max)
+	for y := 0; y < pam.height; y++ {
+		row := pgm.Row(y)
+		for x := range row {
+			row[x] = pam.gray(x, y)
+		}
+	}
+	return pgm
+}
+
+func (pam *PAM) ToPPM() *PPM {
+	colors := pam.colors()
+	ppm := NewPPM(pam.width, pam.height, pam.max)
+	for y := 0; y < pam.height; y++ {
+		row := ppm.Row(y)
+		for x := range row {
+			tuple := pam.data[y][x*pam.depth : (x+1)*pam.depth]
+			if colors >= 3 {
+				row[x] = Pixel{tuple[0], tuple[1], tuple[2]}
+			} else {
+				row[x] = Pixel{tuple[0], tuple[0], tuple[0]}
+			}
+		}
+	}
+	return ppm
+}
+
+// ToPAM converts the bitmap to a BLACKANDWHITE PAM, where set pixels are 0.
+func (pbm *PBM) ToPAM() *PAM {
+	pam := NewPAM(pbm.width, pbm.height, 1, 1, "BLACKANDWHITE")
+	for y := 0; y < pbm.height; y++ {
+		for x, set := range pbm.Row(y) {
+			if !set {
+				pam.data[y][x] = 1
+			}
+		}
+	}
+	return pam
+}
+
+// ToPAM converts the graymap to a GRAYSCALE PAM.
+func (pgm *PGM) ToPAM() *PAM {
+	pam := NewPAM(pgm.width, pgm.height, 1, pgm.max, "GRAYSCALE")
+	for y := 0; y < pgm.height; y++ {
+		copy(pam.data[y], pgm.Row(y))
+	}
+	return pam
+}
+
+// ToPAM converts the pixmap to an RGB PAM.
+func (ppm *PPM) ToPAM() *PAM {
+	pam := NewPAM(ppm.width, ppm.height, 3, ppm.max, "RGB")
+	for y := 0; y < ppm.height; y++ {
+		for x, pixel := range ppm.Row(y) {
+			pam.data[y][x*3], pam.data[y][x*3+1], pam.data[y][x*3+2] = pixel.R, pixel.G, pixel.B
+		}
+	}
+	return pam
+}
+
+// Image returns a view of the PAM as a draw.Image; images with alpha use non-premultiplied colors.
+func (pam *PAM) Image() draw.Image {
+	return pamImage{pam}
+}
+
+type pamImage struct {
+	pam *PAM
+}
+
+func (img pamImage) ColorModel() color.Model {
+	switch {
+	case img.pam.HasAlpha() && img.pam.max > 255:
+		return color.NRGBA64Model
+	case img.pam.HasAlpha():
+		return color.NRGBAModel
+	case img.pam.colors() >= 3:
+		return rgbModel(img.pam.max)
+	}
+	return grayModel(img.pam.max)
+}
+
+func (img pamImage) Bounds() image.Rectangle {
+	return image.Rect(0, 0, img.pam.width, img.pam.height)
+}
+
+func (img pamImage) At(x, y int) color.Color {
+	if !(image.Point{x, y}.In(img.Bounds())) {
+		return color.NRGBA{}
+	}
+	pam := img.pam
+	tuple := pam.data[y][x*pam.depth : (x+1)*pam.depth]
+	max := uint32(pam.max)
+	top := uint32(255)
+	if max > 255 {
+		top = 65535
+	}
+	r := scale(uint32(tuple[0]), max, top)
+	g, b := r, r
+	if pam.colors() >= 3 {
+		g, b = scale(uint32(tuple[1]), max, top), scale(uint32(tuple[2]), max, top)
+	}
+	switch {
+	case pam.HasAlpha() && top == 65535:
+		return color.NRGBA64{uint16(r), uint16(g), uint16(b), uint16(scale(uint32(tuple[pam.depth-1]), max, top))}
+	case pam.HasAlpha():
+		return color.NRGBA{uint8(r), uint8(g), uint8(b), uint8(scale(uint32(tuple[pam.depth-1]), max, top))}
+	case pam.colors() >= 3 && top == 65535:
+		return color.RGBA64{uint16(r), uint16(g), uint16(b), 65535}
+	case pam.colors() >= 3:
+		return color.RGBA{uint8(r), uint8(g), uint8(b), 255}
+	case top == 65535:
+		return color.Gray16{Y: uint16(r)}
+	}
+	return color.Gray{Y: uint8(r)}
+}
+
+func (img pamImage) Set(x, y int, c color.Color) {
+	if !(image.Point{x, y}.In(img.Bounds())) {
+		return
+	}
+	pam := img.pam
+	nrgba := color.NRGBA64Model.Convert(c).(color.NRGBA64)
+	max := uint32(pam.max)
+	tuple := pam.data[y][x*pam.depth : (x+1)*pam.depth]
+	if pam.HasAlpha() {
+		tuple[pam.depth-1] = uint16(scale(uint32(nrgba.A), 65535, max))
+	}
+	if pam.colors() >= 3 {
+		tuple[0] = uint16(scale(uint32(nrgba.R), 65535, max))
+		tuple[1] = uint16(scale(uint32(nrgba.G), 65535, max))
+		tuple[2] = uint16(scale(uint32(nrgba.B), 65535, max))
+	} else {
+		gray := color.Gray16Model.Convert(color.RGBA64{nrgba.R, nrgba.G, nrgba.B, 65535}).(color.Gray16)
+		tuple[0] = uint16(scale(uint32(gray.Y), 65535, max))
+	}
+}
+
+func decodePAMImage(r io.Reader) (image.Image, error) {
+	pam, err := DecodePAM(r)
+	if err != nil {
+		return nil, err
+	}
+	return pam.Image(), nil
+}
+
+func decodePAMConfig(r io.Reader) (image.Config, error) {
+	tok := newTokenizer(r)
+	magicNumber, err := tok.nextMagic()
+	if err != nil {
+		return image.Config{}, err
+	}
+	pam, err := decodePAMHeader(tok, magicNumber)
+	if err != nil {
+		return image.Config{}, err
+	}
+	return image.Config{ColorModel: pam.Image().ColorModel(), Width: pam.width, Height: pam.height}, nil
+}
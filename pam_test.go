@@ -0,0 +1,102 @@
+package Netpbm
+
+import (
+	"bytes"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestPAMFromPPM(t *testing.T) {
+	ppm, err := ReadPPM("./testImages/ppm/testP3.ppm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pam := ppm.ToPAM()
+	if pam.Depth() != 3 || pam.TupleType() != "RGB" || pam.HasAlpha() {
+		t.Error("Wrong PAM shape")
+	}
+	pam.AddAlpha()
+	if pam.Depth() != 4 || pam.TupleType() != "RGB_ALPHA" || !pam.HasAlpha() {
+		t.Error("Alpha not added correctly")
+	}
+	pam.Set(0, 0, []uint16{10, 20, 30, 0})
+
+	var buffer bytes.Buffer
+	err = pam.Encode(&buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := Decode(&buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pam, ok := img.(*PAM)
+	if !ok {
+		t.Fatalf("Expected a PAM, got %T", img)
+	}
+	if pam.width != imagePPMWidth || pam.height != imagePPMHeight || pam.max != imagePPMMax || pam.TupleType() != "RGB_ALPHA" {
+		t.Errorf("Wrong header after decoding %+v", pam)
+	}
+	if pam.Image().At(0, 0) != (color.NRGBA{10, 20, 30, 0}) {
+		t.Errorf("Wrong color %v", pam.Image().At(0, 0))
+	}
+	if pam.At(1, 0)[3] != 255 {
+		t.Error("Synthesised alpha is not opaque")
+	}
+
+	back := pam.ToPPM()
+	for i := 1; i < imageWidth*imageHeight; i++ {
+		x := i % imageWidth
+		y := i / imageWidth
+		if back.At(x, y) != imagePPMData[i] {
+			t.Errorf("Pixel at (%d, %d) not converted correctly", x, y)
+		}
+	}
+}
+
+func TestPAMFromPBM(t *testing.T) {
+	pbm, err := ReadPBM("./testImages/pbm/testP1.pbm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pam := pbm.ToPAM()
+	if pam.TupleType() != "BLACKANDWHITE" || pam.max != 1 {
+		t.Error("Wrong PAM shape")
+	}
+	if pam.At(0, 8)[0] != 0 || pam.At(0, 0)[0] != 1 {
+		t.Error("Set pixels should be black")
+	}
+	back := pam.ToPBM()
+	for i := 0; i < imageWidth*imageHeight; i++ {
+		x := i % imageWidth
+		y := i / imageWidth
+		if back.At(x, y) != imageDataP1[i] {
+			t.Errorf("Pixel at (%d, %d) not converted correctly", x, y)
+		}
+	}
+}
+
+func TestDecodePAMHeader(t *testing.T) {
+	data := "P7\n# made by hand\nWIDTH 2\nHEIGHT 1\nDEPTH 2\nMAXVAL 1023\nTUPLTYPE GRAYSCALE\nTUPLTYPE _ALPHA\nENDHDR\n" +
+		"\x03\xff\x00\x00\x01\x00\x03\xff"
+	pam, err := DecodePAM(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pam.TupleType() != "GRAYSCALE _ALPHA" || pam.Depth() != 2 || pam.max != 1023 {
+		t.Errorf("Wrong header %+v", pam)
+	}
+	if pam.At(0, 0)[0] != 1023 || pam.At(0, 0)[1] != 0 || pam.At(1, 0)[0] != 256 {
+		t.Error("Wrong samples")
+	}
+	pgm := pam.ToPGM()
+	if pgm.max != 1023 || pgm.At(0, 0) != 1023 || pgm.At(1, 0) != 256 {
+		t.Error("Wrong PGM conversion")
+	}
+
+	_, err = DecodePAM(strings.NewReader("P7\nWIDTH 2\nHEIGHT 1\nMAXVAL 255\nENDHDR\n"))
+	if err == nil {
+		t.Error("Expected an error for a missing depth")
+	}
+}
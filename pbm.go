@@ -3,113 +3,213 @@ package Netpbm
 import (
 	"bufio"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
-	"strconv"
-	"strings"
 )
 
+/*
+The Raster holds the pixels, true for a set (black) pixel, and brings the
+pixel access and geometry. Comments are the header comments, without the
+'#', and are written back after the magic number.
+*/
 type PBM struct {
-	data          [][]bool
-	width, height int
-	magicNumber   string
+	Raster[bool]
+	magicNumber string
+	Comments    []string
+}
+
+// NewPBM returns a plain (P1) bitmap of the given size with every pixel unset.
+func NewPBM(width, height int) *PBM {
+	return &PBM{Raster: *NewRaster[bool](width, height), magicNumber: "P1"}
 }
 
 /* Here we have width, height, and pixel data.*/
-func ReadPBM(filename string) (*PBM, error) {
+func ReadPBM(filename string, opts ...DecodeOption) (*PBM, error) {
 	file, err := os.Open(filename)
 	if err != nil {
 		return nil, err
 	}
 	defer file.Close()
-	/*Là je crée un scanner pour lire dans le fichier puisque j'ai déjà fait du java*/
-	scanner := bufio.NewScanner(file)
-
-	var pbm PBM
-	scanner.Scan()
-	pbm.magicNumber = scanner.Text()
-
-	scanner.Scan()
-	fmt.Sscanf(scanner.Text(), "%d %d", &pbm.width, &pbm.height)
-
-	pbm.data = make([][]bool, pbm.height)
-	for i := 0; i < pbm.height && scanner.Scan(); i++ {
-		line := strings.Fields(scanner.Text())
-		pbm.data[i] = make([]bool, pbm.width) /*Here it's initialize the slice for storing pixel values for the current line.*/
-		for j := 0; j < pbm.width; j++ {
-			if j < len(line) {
-				value, err := strconv.Atoi(line[j])
-				if err != nil {
-					return nil, err
-				}
-				pbm.data[i][j] = value != 0
-			} else {
-				return nil, fmt.Errorf("Not enough data at line %d", i+1)
+	return DecodePBM(file, fileOptions(filename, opts)...)
+}
+
+// ReadPBMFS is ReadPBM for a file of fsys, such as an embed.FS.
+func ReadPBMFS(fsys fs.FS, name string, opts ...DecodeOption) (*PBM, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return DecodePBM(file, fileOptions(name, opts)...)
+}
+
+// DecodePBM reads a P1 or P4 bitmap from r.
+func DecodePBM(r io.Reader, opts ...DecodeOption) (*PBM, error) {
+	/*Là je crée un tokenizer qui ignore les commentaires et la mise en page des lignes*/
+	tok := newTokenizer(r, opts...)
+
+	magicNumber, err := tok.nextMagic()
+	if err != nil {
+		return nil, err
+	}
+	if magicNumber != "P1" && magicNumber != "P4" {
+		return nil, tok.tokenError("P1 or P4", fmt.Sprintf("%q", magicNumber), ErrBadMagic)
+	}
+	return decodePBM(tok, magicNumber)
+}
+
+/*decodePBM reads what follows the magic number, it is shared with Decode.*/
+func decodePBM(tok *tokenizer, magicNumber string) (*PBM, error) {
+	pbm := PBM{magicNumber: magicNumber}
+	var err error
+	if pbm.width, err = tok.nextDimension("width"); err != nil {
+		return nil, err
+	}
+	if pbm.height, err = tok.nextDimension("height"); err != nil {
+		return nil, err
+	}
+	if err = tok.checkSize(pbm.width, pbm.height, 1); err != nil {
+		return nil, err
+	}
+	pbm.Comments = tok.headerComments()
+
+	pbm.Raster = *NewRaster[bool](pbm.width, pbm.height)
+	if pbm.magicNumber == "P4" {
+		/*Each row is packed 8 pixels per byte, most significant bit first, padded to a full byte*/
+		buffer := make([]byte, (pbm.width+7)/8)
+		for i := 0; i < pbm.height; i++ {
+			if err := tok.readFull(buffer, fmt.Sprintf("row %d", i+1)); err != nil {
+				return nil, err
+			}
+			row := pbm.Row(i)
+			for j := range row {
+				row[j] = buffer[j/8]&(0x80>>uint(j%8)) != 0
 			}
 		}
+		return &pbm, nil
+	}
+
+	for i := 0; i < pbm.height; i++ {
+		row := pbm.Row(i) /*Here it's the slice storing pixel values for the current line.*/
+		for j := range row {
+			value, err := tok.nextBit()
+			if err != nil {
+				return nil, err
+			}
+			row[j] = value
+		}
+	}
+	if err := tok.checkEnd(pbm.width*pbm.height, true); err != nil {
+		return nil, err
 	}
 
 	return &pbm, nil
 }
 
-func (pbm *PBM) Size() (int, int) {
-	return pbm.width, pbm.height
+// Format returns the magic number, P1 or P4.
+func (pbm *PBM) Format() string {
+	return pbm.magicNumber
 }
 
-func (pbm *PBM) At(x, y int) bool {
-	return pbm.data[y][x]
+// MaxValue is always 1 for a bitmap.
+func (pbm *PBM) MaxValue() uint16 {
+	return 1
 }
 
-func (pbm *PBM) Set(x, y int, value bool) {
-	pbm.data[y][x] = value
+// Clone returns a deep copy of the bitmap as a *PBM.
+func (pbm *PBM) Clone() Image {
+	clone := *pbm
+	clone.Raster = *pbm.Raster.Clone()
+	clone.Comments = append([]string(nil), pbm.Comments...)
+	return &clone
 }
 
-func (pbm *PBM) Save(filename string) error {
-	file, err := os.Create(filename)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
+func (pbm *PBM) Save(filename string, opts ...SaveOption) error {
+	return saveFile(filename, pbm.Encode, opts)
+}
 
-	writer := bufio.NewWriter(file)
+// Encode writes the bitmap to w, raw if the magic number is P4 and plain otherwise.
+func (pbm *PBM) Encode(w io.Writer) error {
+	writer := bufio.NewWriter(w)
 
-	fmt.Fprintf(writer, "%s\n%d %d\n", pbm.magicNumber, pbm.width, pbm.height)
+	fmt.Fprintf(writer, "%s\n", pbm.magicNumber)
+	writeComments(writer, pbm.Comments)
+	fmt.Fprintf(writer, "%d %d\n", pbm.width, pbm.height)
 
-	for _, row := range pbm.data {
-		for _, pixel := range row {
-			if pixel {
-				fmt.Fprint(writer, "1 ")
-			} else {
-				fmt.Fprint(writer, "0 ")
+	if pbm.magicNumber == "P4" {
+		buffer := make([]byte, (pbm.width+7)/8)
+		for y := 0; y < pbm.height; y++ {
+			for i := range buffer {
+				buffer[i] = 0
+			}
+			for j, pixel := range pbm.Row(y) {
+				if pixel {
+					buffer[j/8] |= 0x80 >> uint(j%8)
+				}
 			}
+			if _, err := writer.Write(buffer); err != nil {
+				return err
+			}
+		}
+		return writer.Flush()
+	}
+
+	plain := newPlainWriter(writer)
+	for y := 0; y < pbm.height; y++ {
+		for _, pixel := range pbm.Row(y) {
+			plain.bit(pixel)
 		}
-		fmt.Fprintln(writer)
+		plain.endLine()
 	}
 
 	return writer.Flush()
 }
 
 func (pbm *PBM) Invert() {
-	for y := 0; y < pbm.height; y++ {
-		for x := 0; x < pbm.width; x++ {
-			pbm.data[y][x] = !pbm.data[y][x]
-		}
-	}
+	pbm.Map(func(pixel bool) bool { return !pixel })
 }
 
-func (pbm *PBM) Flip() {
+// ToPGM returns a graymap with a max value of 1, set pixels becoming 0.
+func (pbm *PBM) ToPGM() *PGM {
+	pgm := NewPGM(pbm.width, pbm.height, 1)
+	pgm.SetComments(pbm.Comments...)
 	for y := 0; y < pbm.height; y++ {
-		for x := 0; x < pbm.width/2; x++ {
-			pbm.data[y][x], pbm.data[y][pbm.width-x-1] = pbm.data[y][pbm.width-x-1], pbm.data[y][x]
+		pgmRow := pgm.Row(y)
+		for x, pixel := range pbm.Row(y) {
+			if !pixel {
+				pgmRow[x] = 1
+			}
 		}
 	}
+	return pgm
 }
 
-func (pbm *PBM) Flop() {
-	for y := 0; y < pbm.height/2; y++ {
-		pbm.data[y], pbm.data[pbm.height-y-1] = pbm.data[pbm.height-y-1], pbm.data[y]
+// ToPPM returns a black and white pixmap with a max value of 1.
+func (pbm *PBM) ToPPM() *PPM {
+	ppm := NewPPM(pbm.width, pbm.height, 1)
+	ppm.SetComments(pbm.Comments...)
+	for y := 0; y < pbm.height; y++ {
+		ppmRow := ppm.Row(y)
+		for x, pixel := range pbm.Row(y) {
+			if !pixel {
+				ppmRow[x] = Pixel{1, 1, 1}
+			}
+		}
 	}
+	return ppm
 }
 
 func (pbm *PBM) SetMagicNumber(magicNumber string) {
 	pbm.magicNumber = magicNumber
 }
+
+// AddComment appends a comment to the header.
+func (pbm *PBM) AddComment(comment string) {
+	pbm.Comments = append(pbm.Comments, comment)
+}
+
+// SetComments replaces the header comments, none removes them all.
+func (pbm *PBM) SetComments(comments ...string) {
+	pbm.Comments = append([]string(nil), comments...)
+}
@@ -1,6 +1,7 @@
 package Netpbm
 
 import (
+	"bytes"
 	"os"
 	"testing"
 )
@@ -103,13 +104,12 @@ func TestReadPBM(t *testing.T) {
 	for i := 0; i < imageWidth*imageHeight; i++ {
 		var x = i % imageWidth
 		var y = i / imageWidth
-		if pbm.data[y][x] != imageDataP1[i] {
+		if pbm.At(x, y) != imageDataP1[i] {
 			t.Error("Wrong data")
 		}
 	}
-	/*J'ai pas réussi à faire le P4 du coup je l'ai mis en commentaire*/
 	// read the image with P4 magic number
-	/*pbm, err = ReadPBM("./testImages/pbm/testP4.pbm")
+	pbm, err = ReadPBM("./testImages/pbm/testP4.pbm")
 	if err != nil {
 		t.Error(err)
 	}
@@ -122,13 +122,13 @@ func TestReadPBM(t *testing.T) {
 	}
 	if pbm.height != 15 {
 		t.Error("Wrong height")
-	}*/
+	}
 
 	// compare the data
 	for i := 0; i < imageWidth*imageHeight; i++ {
 		var x = i % imageWidth
 		var y = i / imageWidth
-		if pbm.data[y][x] != imageDataP1[i] {
+		if pbm.At(x, y) != imageDataP1[i] {
 			t.Error("Wrong data")
 		}
 	}
@@ -193,12 +193,12 @@ func TestSave(t *testing.T) {
 	for i := 0; i < imageWidth*imageHeight; i++ {
 		var x = i % imageWidth
 		var y = i / imageWidth
-		if pbm2.data[y][x] != imageDataP1[i] {
+		if pbm2.At(x, y) != imageDataP1[i] {
 			t.Error("Wrong data")
 		}
 	}
 
-	/*pbm, err = ReadPBM("./testImages/pbm/testP4.pbm")
+	pbm, err = ReadPBM("./testImages/pbm/testP4.pbm")
 	if err != nil {
 		t.Error(err)
 	}
@@ -213,7 +213,7 @@ func TestSave(t *testing.T) {
 	}
 	if pbm2.magicNumber != "P4" {
 		t.Error("Wrong magic number")
-	}*/
+	}
 	if pbm2.width != 15 {
 		t.Error("Wrong width")
 	}
@@ -224,7 +224,7 @@ func TestSave(t *testing.T) {
 	for i := 0; i < imageWidth*imageHeight; i++ {
 		var x = i % imageWidth
 		var y = i / imageWidth
-		if pbm2.data[y][x] != imageDataP1[i] {
+		if pbm2.At(x, y) != imageDataP1[i] {
 			t.Error("Wrong data")
 		}
 	}
@@ -233,10 +233,10 @@ func TestSave(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	/*err = os.Remove("./testImages/pbm/testP4Save.pbm")
+	err = os.Remove("./testImages/pbm/testP4Save.pbm")
 	if err != nil {
 		t.Error(err)
-	}*/
+	}
 }
 
 func TestInvert(t *testing.T) {
@@ -249,7 +249,7 @@ func TestInvert(t *testing.T) {
 	for i := 0; i < imageWidth*imageHeight; i++ {
 		var x = i % imageWidth
 		var y = i / imageWidth
-		if pbm.data[y][x] != imageDataInvert[i] {
+		if pbm.At(x, y) != imageDataInvert[i] {
 			t.Error("Wrong data")
 		}
 	}
@@ -265,7 +265,7 @@ func TestFlip(t *testing.T) {
 	for i := 0; i < imageWidth*imageHeight; i++ {
 		var x = i % imageWidth
 		var y = i / imageWidth
-		if pbm.data[y][x] != imageDataFlip[i] {
+		if pbm.At(x, y) != imageDataFlip[i] {
 			t.Error("Wrong data")
 		}
 	}
@@ -281,7 +281,7 @@ func TestFlop(t *testing.T) {
 	for i := 0; i < imageWidth*imageHeight; i++ {
 		var x = i % imageWidth
 		var y = i / imageWidth
-		if pbm.data[y][x] != imageDataFlop[i] {
+		if pbm.At(x, y) != imageDataFlop[i] {
 			t.Error("Wrong data")
 		}
 	}
@@ -297,3 +297,29 @@ func TestSetMagicNumber(t *testing.T) {
 		t.Error("Wrong magic number")
 	}
 }
+
+func TestDecodeEncode(t *testing.T) {
+	original, err := os.ReadFile("./testImages/pbm/testP4.pbm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pbm, err := DecodePBM(bytes.NewReader(original))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < imageWidth*imageHeight; i++ {
+		var x = i % imageWidth
+		var y = i / imageWidth
+		if pbm.At(x, y) != imageDataP1[i] {
+			t.Error("Wrong data")
+		}
+	}
+	var buffer bytes.Buffer
+	err = pbm.Encode(&buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buffer.Bytes(), original) {
+		t.Error("Encoded data differs from the original")
+	}
+}
@@ -0,0 +1,280 @@
+package Netpbm
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"strconv"
+)
+
+/*
+PFM is a Portable Float Map: "Pf" holds one float32 sample per pixel and
+"PF" holds red, green and blue. Samples are linear light and are not
+limited to 0..1. Rows are kept top to bottom here, the file stores them
+bottom to top.
+*/
+type PFM struct {
+	data          [][]float32 /*each row holds width*channels samples*/
+	width, height int
+	magicNumber   string
+	scale         float32 /*absolute value of the header scale*/
+	littleEndian  bool    /*a negative header scale means little-endian samples*/
+}
+
+// NewPFM returns a black "Pf" or "PF" image of the given size, stored little-endian.
+func NewPFM(width, height int, magicNumber string) *PFM {
+	pfm := &PFM{
+		data:         make([][]float32, height),
+		width:        width,
+		height:       height,
+		magicNumber:  magicNumber,
+		scale:        1,
+		littleEndian: true,
+	}
+	for y := range pfm.data {
+		pfm.data[y] = make([]float32, width*pfm.Channels())
+	}
+	return pfm
+}
+
+func ReadPFM(filename string, opts ...DecodeOption) (*PFM, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return DecodePFM(file, fileOptions(filename, opts)...)
+}
+
+// ReadPFMFS is ReadPFM for a file of fsys, such as an embed.FS.
+func ReadPFMFS(fsys fs.FS, name string, opts ...DecodeOption) (*PFM, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return DecodePFM(file, fileOptions(name, opts)...)
+}
+
+// DecodePFM reads a "Pf" or "PF" float map from r.
+func DecodePFM(r io.Reader, opts ...DecodeOption) (*PFM, error) {
+	tok := newTokenizer(r, opts...)
+	pfm := &PFM{}
+	var err error
+	pfm.magicNumber, err = tok.nextMagic()
+	if err != nil {
+		return nil, err
+	}
+	if pfm.magicNumber != "Pf" && pfm.magicNumber != "PF" {
+		return nil, tok.tokenError("Pf or PF", fmt.Sprintf("%q", pfm.magicNumber), ErrBadMagic)
+	}
+	if pfm.width, err = tok.nextDimension("width"); err != nil {
+		return nil, err
+	}
+	if pfm.height, err = tok.nextDimension("height"); err != nil {
+		return nil, err
+	}
+	if err = tok.checkSize(pfm.width, pfm.height, 4*int64(pfm.Channels())); err != nil {
+		return nil, err
+	}
+	token, err := tok.next()
+	if err != nil {
+		return nil, tok.endError("scale", err)
+	}
+	scale, err := strconv.ParseFloat(token, 32)
+	if err != nil || scale == 0 {
+		return nil, tok.tokenError("non-zero scale", fmt.Sprintf("%q", token), ErrSyntax)
+	}
+	pfm.littleEndian = scale < 0
+	pfm.scale = float32(math.Abs(scale))
+
+	var order binary.ByteOrder = binary.BigEndian
+	if pfm.littleEndian {
+		order = binary.LittleEndian
+	}
+	buffer := make([]byte, pfm.width*pfm.Channels()*4)
+	pfm.data = make([][]float32, pfm.height)
+	/*The first row in the file is the bottom one*/
+	for i := pfm.height - 1; i >= 0; i-- {
+		if err := tok.readFull(buffer, fmt.Sprintf("row %d", pfm.height-i)); err != nil {
+			return nil, err
+		}
+		pfm.data[i] = make([]float32, pfm.width*pfm.Channels())
+		for j := range pfm.data[i] {
+			pfm.data[i][j] = math.Float32frombits(order.Uint32(buffer[j*4:]))
+		}
+	}
+	return pfm, nil
+}
+
+func (pfm *PFM) Size() (int, int) {
+	return pfm.width, pfm.height
+}
+
+// Channels is 1 for "Pf" and 3 for "PF".
+func (pfm *PFM) Channels() int {
+	if pfm.magicNumber == "PF" {
+		return 3
+	}
+	return 1
+}
+
+// At returns a copy of the samples of the pixel at (x, y), all zeros outside the bounds like Raster.At.
+func (pfm *PFM) At(x, y int) []float32 {
+	channels := pfm.Channels()
+	samples := make([]float32, channels)
+	if x < 0 || y < 0 || x >= pfm.width || y >= pfm.height {
+		return samples
+	}
+	copy(samples, pfm.data[y][x*channels:(x+1)*channels])
+	return samples
+}
+
+// Set does nothing outside the bounds, like Raster.Set.
+func (pfm *PFM) Set(x, y int, samples []float32) {
+	if x < 0 || y < 0 || x >= pfm.width || y >= pfm.height {
+		return
+	}
+	channels := pfm.Channels()
+	copy(pfm.data[y][x*channels:(x+1)*channels], samples)
+}
+
+func (pfm *PFM) Save(filename string, opts ...SaveOption) error {
+	return saveFile(filename, pfm.Encode, opts)
+}
+
+// Encode writes the float map to w, keeping the byte order it was read with.
+func (pfm *PFM) Encode(w io.Writer) error {
+	writer := bufio.NewWriter(w)
+
+	scale := float64(pfm.scale)
+	if scale == 0 {
+		scale = 1
+	}
+	var order binary.ByteOrder = binary.BigEndian
+	if pfm.littleEndian {
+		order = binary.LittleEndian
+		scale = -scale
+	}
+	fmt.Fprintf(writer, "%s\n%d %d\n%s\n", pfm.magicNumber, pfm.width, pfm.height, strconv.FormatFloat(scale, 'f', -1, 32))
+
+	buffer := make([]byte, pfm.width*pfm.Channels()*4)
+	for i := pfm.height - 1; i >= 0; i-- {
+		for j, value := range pfm.data[i] {
+			order.PutUint32(buffer[j*4:], math.Float32bits(value))
+		}
+		if _, err := writer.Write(buffer); err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
+
+/*
+ToneMap maps a linear sample to the 0..1 range before it is quantized by
+ToPGM or ToPPM.
+*/
+type ToneMap func(value float32) float32
+
+func clamp(value float32) float32 {
+	if !(value > 0) {
+		return 0
+	}
+	if value > 1 {
+		return 1
+	}
+	return value
+}
+
+// Clip keeps 0..1 unchanged and clips everything outside it.
+func Clip(value float32) float32 {
+	return clamp(value)
+}
+
+// Reinhard compresses highlights with v / (1 + v), so no value is ever clipped.
+func Reinhard(value float32) float32 {
+	if !(value > 0) {
+		return 0
+	}
+	if math.IsInf(float64(value), 1) {
+		return 1
+	}
+	return clamp(value / (1 + value))
+}
+
+// Exposure scales by 2^stops, then clips.
+func Exposure(stops float64) ToneMap {
+	factor := float32(math.Pow(2, stops))
+	return func(value float32) float32 {
+		return clamp(value * factor)
+	}
+}
+
+func quantize(value float32, toneMap ToneMap, max uint16) uint16 {
+	return uint16(math.Round(float64(toneMap(value)) * float64(max)))
+}
+
+// ToPGM tone maps the float map down to a plain graymap; colors are averaged.
+func (pfm *PFM) ToPGM(toneMap ToneMap, max uint16) *PGM {
+	channels := pfm.Channels()
+	pgm := NewPGM(pfm.width, pfm.height, max)
+	for y := 0; y < pfm.height; y++ {
+		row := pgm.Row(y)
+		for x := range row {
+			var sum float32
+			for _, value := range pfm.data[y][x*channels : (x+1)*channels] {
+				sum += value
+			}
+			row[x] = quantize(sum/float32(channels), toneMap, max)
+		}
+	}
+	return pgm
+}
+
+// ToPPM tone maps the float map down to a plain pixmap; gray maps fill all three channels.
+func (pfm *PFM) ToPPM(toneMap ToneMap, max uint16) *PPM {
+	channels := pfm.Channels()
+	ppm := NewPPM(pfm.width, pfm.height, max)
+	for y := 0; y < pfm.height; y++ {
+		row := ppm.Row(y)
+		for x := range row {
+			samples := pfm.data[y][x*channels : (x+1)*channels]
+			if channels == 3 {
+				row[x] = Pixel{quantize(samples[0], toneMap, max), quantize(samples[1], toneMap, max), quantize(samples[2], toneMap, max)}
+			} else {
+				value := quantize(samples[0], toneMap, max)
+				row[x] = Pixel{value, value, value}
+			}
+		}
+	}
+	return ppm
+}
+
+// ToPFM promotes the graymap to a "Pf" float map, max becoming 1.0.
+func (pgm *PGM) ToPFM() *PFM {
+	pfm := NewPFM(pgm.width, pgm.height, "Pf")
+	for y := 0; y < pgm.height; y++ {
+		for x, value := range pgm.Row(y) {
+			pfm.data[y][x] = float32(value) / float32(pgm.max)
+		}
+	}
+	return pfm
+}
+
+// ToPFM promotes the pixmap to a "PF" float map, max becoming 1.0.
+func (ppm *PPM) ToPFM() *PFM {
+	pfm := NewPFM(ppm.width, ppm.height, "PF")
+	max := float32(ppm.max)
+	for y := 0; y < ppm.height; y++ {
+		for x, pixel := range ppm.Row(y) {
+			pfm.data[y][x*3] = float32(pixel.R) / max
+			pfm.data[y][x*3+1] = float32(pixel.G) / max
+			pfm.data[y][x*3+2] = float32(pixel.B) / max
+		}
+	}
+	return pfm
+}
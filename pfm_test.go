@@ -0,0 +1,95 @@
+package Netpbm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+func TestDecodePFM(t *testing.T) {
+	var data bytes.Buffer
+	data.WriteString("Pf\n2 2\n1\n")
+	/*big-endian, bottom row first*/
+	for _, value := range []float32{3, 4, 1, 2} {
+		binary.Write(&data, binary.BigEndian, value)
+	}
+	original := append([]byte(nil), data.Bytes()...)
+	pfm, err := DecodePFM(&data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pfm.Channels() != 1 || pfm.littleEndian || pfm.scale != 1 {
+		t.Errorf("Wrong header %+v", pfm)
+	}
+	if pfm.At(0, 0)[0] != 1 || pfm.At(1, 0)[0] != 2 || pfm.At(0, 1)[0] != 3 || pfm.At(1, 1)[0] != 4 {
+		t.Errorf("Wrong samples %v", pfm.data)
+	}
+
+	var buffer bytes.Buffer
+	err = pfm.Encode(&buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buffer.Bytes(), original) {
+		t.Errorf("Encoded data differs from the original %q", buffer.Bytes())
+	}
+}
+
+func TestPFMRoundTrip(t *testing.T) {
+	ppm, err := ReadPPM("./testImages/ppm/testP3.ppm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pfm := ppm.ToPFM()
+	if pfm.Channels() != 3 || pfm.At(9, 3)[2] != 0 || pfm.At(0, 0)[0] != 1 {
+		t.Error("Wrong promotion")
+	}
+	var buffer bytes.Buffer
+	err = pfm.Encode(&buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(buffer.Bytes(), []byte("PF\n15 15\n-1\n")) {
+		t.Errorf("Wrong header %q", buffer.Bytes()[:12])
+	}
+	pfm, err = DecodePFM(&buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	back := pfm.ToPPM(Clip, 255)
+	for i := 0; i < imageWidth*imageHeight; i++ {
+		x := i % imageWidth
+		y := i / imageWidth
+		if back.At(x, y) != imagePPMData[i] {
+			t.Errorf("Pixel at (%d, %d) not converted correctly", x, y)
+		}
+	}
+}
+
+func TestToneMap(t *testing.T) {
+	pfm := NewPFM(4, 1, "Pf")
+	pfm.Set(0, 0, []float32{-1})
+	pfm.Set(1, 0, []float32{0.5})
+	pfm.Set(2, 0, []float32{1})
+	pfm.Set(3, 0, []float32{float32(math.Inf(1))})
+
+	expected := map[string][]uint16{
+		"clip":     {0, 128, 255, 255},
+		"reinhard": {0, 85, 128, 255},
+		"exposure": {0, 64, 128, 255},
+	}
+	toneMaps := map[string]ToneMap{
+		"clip":     Clip,
+		"reinhard": Reinhard,
+		"exposure": Exposure(-1),
+	}
+	for name, toneMap := range toneMaps {
+		pgm := pfm.ToPGM(toneMap, 255)
+		for x, want := range expected[name] {
+			if pgm.At(x, 0) != want {
+				t.Errorf("%s: wrong value at %d, expected %d got %d", name, x, want, pgm.At(x, 0))
+			}
+		}
+	}
+}
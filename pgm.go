@@ -2,217 +2,260 @@ package Netpbm
 
 import (
 	"bufio"
-	"errors"
+	"encoding/binary"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
-	"strconv"
-	"strings"
 )
 
+/*
+The Raster holds the samples and brings the pixel access and geometry.
+Comments are the header comments, without the '#'.
+*/
 type PGM struct {
+	Raster[uint16]
 	magicNumber string
-	width       int
-	height      int
-	max         uint8
-	data        [][]uint8
+	max         uint16
+	Comments    []string
 }
 
-func ReadPGM(filename string) (*PGM, error) {
-	pgm := PGM{}
+// NewPGM returns a plain (P2) graymap of the given size with every sample set to 0; a max value of 0 is taken as 1.
+func NewPGM(width, height int, max uint16) *PGM {
+	return &PGM{Raster: *NewRaster[uint16](width, height), magicNumber: "P2", max: validMax(max)}
+}
+
+/*validMax turns a max value of 0, which no decoder accepts and which rescaling would divide by, into 1.*/
+func validMax(max uint16) uint16 {
+	if max == 0 {
+		return 1
+	}
+	return max
+}
+
+/*sampleSize is the size in bytes of a raw sample: two bytes big-endian above 255.*/
+func sampleSize(max uint16) int {
+	if max > 255 {
+		return 2
+	}
+	return 1
+}
+
+func ReadPGM(filename string, opts ...DecodeOption) (*PGM, error) {
 	file, err := os.Open(filename)
 	if err != nil {
-		return nil, fmt.Errorf("error opening file: %v", err)
+		return nil, fmt.Errorf("error opening file: %w", err)
 	}
 	defer file.Close()
+	return DecodePGM(file, fileOptions(filename, opts)...)
+}
 
-	/*Scanner for text-based information*/
-	scanner := bufio.NewScanner(file)
+// ReadPGMFS is ReadPGM for a file of fsys, such as an embed.FS.
+func ReadPGMFS(fsys fs.FS, name string, opts ...DecodeOption) (*PGM, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return DecodePGM(file, fileOptions(name, opts)...)
+}
 
-	scanner.Scan()
-	pgm.magicNumber = scanner.Text()
-	if pgm.magicNumber != "P5" && pgm.magicNumber != "P2" {
-		return nil, fmt.Errorf("invalid magic number: %s", pgm.magicNumber)
+// DecodePGM reads a P2 or P5 graymap from r.
+func DecodePGM(r io.Reader, opts ...DecodeOption) (*PGM, error) {
+	/*Tokenizer for the header, it stops right after the whitespace that ends max value*/
+	tok := newTokenizer(r, opts...)
+
+	magicNumber, err := tok.nextMagic()
+	if err != nil {
+		return nil, err
 	}
+	if magicNumber != "P5" && magicNumber != "P2" {
+		return nil, tok.tokenError("P2 or P5", fmt.Sprintf("%q", magicNumber), ErrBadMagic)
+	}
+	return decodePGM(tok, magicNumber)
+}
+
+/*decodePGM reads what follows the magic number, it is shared with Decode.*/
+func decodePGM(tok *tokenizer, magicNumber string) (*PGM, error) {
+	pgm := PGM{magicNumber: magicNumber}
+	var err error
 
 	/*Donnons les dimensions ici*/
-	scanner.Scan()
-	sepa := strings.Fields(scanner.Text())
-	if len(sepa) != 2 {
-		return nil, errors.New("bad input format")
+	if pgm.width, err = tok.nextDimension("width"); err != nil {
+		return nil, err
 	}
-	pgm.width, _ = strconv.Atoi(sepa[0])
-	pgm.height, _ = strconv.Atoi(sepa[1])
-
-	if pgm.width <= 0 || pgm.height <= 0 {
-		return nil, fmt.Errorf("invalid size: %d x %d", pgm.width, pgm.height)
+	if pgm.height, err = tok.nextDimension("height"); err != nil {
+		return nil, err
+	}
+	if err = tok.checkSize(pgm.width, pgm.height, 2); err != nil {
+		return nil, err
 	}
 
 	/*Get max value*/
-	scanner.Scan()
-	maxValue, err := strconv.Atoi(scanner.Text())
-	if err != nil {
-		return nil, fmt.Errorf("error parsing max value: %v", err)
+	if pgm.max, err = tok.nextMaxValue(); err != nil {
+		return nil, err
 	}
-	pgm.max = uint8(maxValue)
-
-	/*Move to the beginning of binary data*/
-	for scanner.Scan() {
-		if scanner.Text() == "" || strings.HasPrefix(scanner.Text(), "#") {
-			continue
-		} else {
-			break
-		}
-	}
-	/*J'ai pas fait réussi  le p5 aussi c'est en commentaire */
+	pgm.Comments = tok.headerComments()
+	pgm.Raster = *NewRaster[uint16](pgm.width, pgm.height)
+
 	// P5 format (raw binary)
 	if pgm.magicNumber == "P5" {
-		/*  buffer := make([]byte, pgm.width*pgm.height)
-
-			// Read the binary data directly into the buffer
-		    _, err := file.Read(buffer)
-		    if err != nil {
-			    return nil, fmt.Errorf("error reading binary data: %v", err)
-			 }
-
-			// Populate pgm.data with the binary data
-			pgm.data = make([][]uint8, pgm.height)
-			for i := 0; i < pgm.height; i++ {
-				pgm.data[i] = make([]uint8, pgm.width)
-			for j := 0; j < pgm.width; j++ {
-				 pgm.data[i][j] = buffer[i*pgm.width+j]
-		   }
-			}*/
+		/*Samples are one byte, or two bytes big-endian when max is above 255*/
+		size := sampleSize(pgm.max)
+		buffer := make([]byte, pgm.width*size)
+
+		/*Read the binary data row by row, starting right after the max value*/
+		for i := 0; i < pgm.height; i++ {
+			if err := tok.readFull(buffer, fmt.Sprintf("row %d", i+1)); err != nil {
+				return nil, err
+			}
+			row := pgm.Row(i)
+			for j := range row {
+				if size == 2 {
+					row[j] = binary.BigEndian.Uint16(buffer[j*2:])
+				} else {
+					row[j] = uint16(buffer[j])
+				}
+				if row[j], err = tok.checkRaw(len(buffer)-j*size, row[j], pgm.max); err != nil {
+					return nil, err
+				}
+			}
+		}
 	} else if pgm.magicNumber == "P2" {
 		/*P2 format (ASCII)*/
-		pgm.data = make([][]uint8, pgm.height)
 		for i := 0; i < pgm.height; i++ {
-			pgm.data[i] = make([]uint8, pgm.width)
-			lineValues := strings.Fields(scanner.Text())
-			if len(lineValues) != pgm.width {
-				return nil, fmt.Errorf("bad row length: %d", len(lineValues))
-			}
-			for j := 0; j < pgm.width; j++ {
-				value, err := strconv.Atoi(lineValues[j])
+			row := pgm.Row(i)
+			for j := range row {
+				value, err := tok.nextSample(pgm.max)
 				if err != nil {
-					return nil, fmt.Errorf("error reading pixel value: %v", err)
+					return nil, err
 				}
-				pgm.data[i][j] = uint8(value)
-			}
-			if !scanner.Scan() {
-				break
+				row[j] = value
 			}
 		}
+		if err := tok.checkEnd(pgm.width*pgm.height, false); err != nil {
+			return nil, err
+		}
 	} else {
 		return nil, fmt.Errorf("unsupported PGM format: %s", pgm.magicNumber)
 	}
 
 	return &pgm, nil
 }
-func (pgm *PGM) Size() (int, int) {
-	return pgm.width, pgm.height
+
+// Format returns the magic number, P2 or P5.
+func (pgm *PGM) Format() string {
+	return pgm.magicNumber
 }
 
-func (pgm *PGM) At(x, y int) uint8 {
-	return pgm.data[y][x]
+func (pgm *PGM) MaxValue() uint16 {
+	return pgm.max
 }
 
-func (pgm *PGM) Set(x, y int, value uint8) {
-	pgm.data[y][x] = value
+// Clone returns a deep copy of the graymap as a *PGM.
+func (pgm *PGM) Clone() Image {
+	clone := *pgm
+	clone.Raster = *pgm.Raster.Clone()
+	clone.Comments = append([]string(nil), pgm.Comments...)
+	return &clone
 }
 
-func (pgm *PGM) Save(filename string) error {
-	file, err := os.Create(filename)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
+func (pgm *PGM) Save(filename string, opts ...SaveOption) error {
+	return saveFile(filename, pgm.Encode, opts)
+}
 
-	writer := bufio.NewWriter(file)
+// Encode writes the graymap to w, raw if the magic number is P5 and plain otherwise.
+func (pgm *PGM) Encode(w io.Writer) error {
+	writer := bufio.NewWriter(w)
 
 	fmt.Fprintln(writer, pgm.magicNumber)
+	writeComments(writer, pgm.Comments)
 
 	/* Here we  Write width, height, and max*/
 	fmt.Fprintf(writer, "%d %d\n", pgm.width, pgm.height)
 	fmt.Fprintf(writer, "%d\n", pgm.max)
 	/*It's pixel data*/
-	for _, row := range pgm.data {
-		for _, value := range row {
-			fmt.Fprintf(writer, "%d ", value)
+	if pgm.magicNumber == "P5" {
+		size := sampleSize(pgm.max)
+		buffer := make([]byte, pgm.width*size)
+		for y := 0; y < pgm.height; y++ {
+			for j, value := range pgm.Row(y) {
+				if size == 2 {
+					binary.BigEndian.PutUint16(buffer[j*2:], value)
+				} else {
+					buffer[j] = uint8(value)
+				}
+			}
+			if _, err := writer.Write(buffer); err != nil {
+				return err
+			}
+		}
+		return writer.Flush()
+	}
+	plain := newPlainWriter(writer)
+	for y := 0; y < pgm.height; y++ {
+		for _, value := range pgm.Row(y) {
+			plain.sample(value)
 		}
-		fmt.Fprintln(writer)
+		plain.endLine()
 	}
 
 	return writer.Flush()
 }
 
 func (pgm *PGM) Invert() {
-	for y := 0; y < pgm.height; y++ {
-		for x := 0; x < pgm.width; x++ {
-			pgm.data[y][x] = uint8(pgm.max) - pgm.data[y][x]
-		}
-	}
+	pgm.Map(func(value uint16) uint16 { return pgm.max - value })
 }
 
-func (pgm *PGM) Flip() {
-	for y := 0; y < pgm.height; y++ {
-		for x := 0; x < pgm.width/2; x++ {
-			pgm.data[y][x], pgm.data[y][pgm.width-x-1] = pgm.data[y][pgm.width-x-1], pgm.data[y][x]
-		}
-	}
+func (pgm *PGM) SetMagicNumber(magicNumber string) {
+	pgm.magicNumber = magicNumber
 }
 
-func (pgm *PGM) Flop() {
-	for y := 0; y < pgm.height/2; y++ {
-		pgm.data[y], pgm.data[pgm.height-y-1] = pgm.data[pgm.height-y-1], pgm.data[y]
-	}
+// AddComment appends a comment to the header.
+func (pgm *PGM) AddComment(comment string) {
+	pgm.Comments = append(pgm.Comments, comment)
 }
 
-func (pgm *PGM) SetMagicNumber(magicNumber string) {
-	pgm.magicNumber = magicNumber
+// SetComments replaces the header comments, none removes them all.
+func (pgm *PGM) SetComments(comments ...string) {
+	pgm.Comments = append([]string(nil), comments...)
 }
 
-func (pgm *PGM) SetMaxValue(maxValue uint8) {
-	scaleFactor := float64(maxValue) / float64(pgm.max)
+// SetMaxValue rescales every sample to the new max value, 0 being taken as 1.
+func (pgm *PGM) SetMaxValue(maxValue uint16) {
+	maxValue = validMax(maxValue)
+	oldMax := pgm.max
 
 	/*Here we Update max value*/
 	pgm.max = maxValue
 
-	for y := 0; y < pgm.height; y++ {
-		for x := 0; x < pgm.width; x++ {
-			pgm.data[y][x] = uint8(float64(pgm.data[y][x]) * scaleFactor)
-		}
-	}
+	pgm.Map(func(value uint16) uint16 { return uint16(scale(uint32(value), uint32(oldMax), uint32(maxValue))) })
 }
 
-func (pgm *PGM) Rotate90CW() {
-	rotated := make([][]uint8, pgm.width)
-	for i := 0; i < pgm.width; i++ {
-		rotated[i] = make([]uint8, pgm.height)
-		for j := 0; j < pgm.height; j++ {
-			rotated[i][j] = pgm.data[pgm.height-j-1][i]
+// ToPPM returns a pixmap with the same max value, every channel set to the gray level.
+func (pgm *PGM) ToPPM() *PPM {
+	ppm := NewPPM(pgm.width, pgm.height, pgm.max)
+	ppm.SetComments(pgm.Comments...)
+	for y := 0; y < pgm.height; y++ {
+		ppmRow := ppm.Row(y)
+		for x, value := range pgm.Row(y) {
+			ppmRow[x] = Pixel{value, value, value}
 		}
 	}
-
-	pgm.data = rotated
-
-	pgm.width, pgm.height = pgm.height, pgm.width
+	return ppm
 }
 
+// ToPBM sets the pixels darker than half max, a set bit being black.
 func (pgm *PGM) ToPBM() *PBM {
-	pbmData := make([][]bool, pgm.height)
+	pbm := NewPBM(pgm.width, pgm.height)
+	pbm.SetComments(pgm.Comments...)
 
 	for y := 0; y < pgm.height; y++ {
-		pbmData[y] = make([]bool, pgm.width)
-
-		for x := 0; x < pgm.width; x++ {
-			pbmData[y][x] = pgm.data[y][x] < uint8(pgm.max)/2
+		pbmRow := pbm.Row(y)
+		for x, value := range pgm.Row(y) {
+			pbmRow[x] = uint32(value)*2 < uint32(pgm.max)
 		}
 	}
 
-	return &PBM{
-		data:        pbmData,
-		width:       pgm.width,
-		height:      pgm.height,
-		magicNumber: "P1",
-	}
+	return pbm
 }
@@ -1,7 +1,10 @@
 package Netpbm
 
 import (
+	"bytes"
+	"io"
 	"os"
+	"reflect"
 	"testing"
 )
 
@@ -9,14 +12,14 @@ const imagePGMWidth = 15
 const imagePGMHeight = 15
 const imagePGMMax = 11
 
-var testData = []uint8{
+var testData = []uint16{
 	11, 11, 11, 11, 11, 11, 11, 0, 0, 0, 0, 11, 11, 11, 11, 11, 11, 11, 11, 11, 11, 0, 11, 11, 11, 11, 0, 11, 11, 11, 11, 11, 11, 11, 11, 0, 11, 11, 11, 11, 11, 11, 0, 11, 11, 11, 11, 11, 11, 11, 0, 11, 11, 11, 11, 8, 11, 0, 0, 0, 11,
 	11, 11, 11, 11, 0, 11, 11, 11, 11, 11, 11, 5, 5, 0, 11, 11, 11, 11, 11, 0, 0, 11, 11, 11, 11, 11, 5, 0, 0, 0, 0, 11, 11, 11, 11, 0, 0, 11, 11, 11, 0, 0, 0, 11, 0, 7, 0, 0, 11, 11, 11, 0, 11, 11, 11, 0, 11, 11, 11, 0, 7, 11, 11, 0,
 	0, 0, 11, 11, 11, 11, 0, 11, 11, 11, 0, 7, 11, 11, 11, 11, 11, 11, 11, 11, 11, 11, 0, 11, 11, 0, 7, 11, 11, 11, 11, 11, 11, 11, 11, 11, 11, 0, 11, 11, 11, 0, 11, 11, 11, 11, 11, 11, 11, 11, 11, 11, 0, 11, 11, 11, 0, 0, 11, 11, 11, 11,
 	11, 11, 11, 11, 0, 11, 11, 11, 11, 11, 0, 0, 7, 7, 7, 7, 7, 0, 0, 11, 11, 11, 11, 11, 11, 11, 11, 0, 0, 0, 0, 0, 0, 11, 11, 11, 11, 11,
 }
 
-var testInvertPGM = []uint8{
+var testInvertPGM = []uint16{
 	0, 0, 0, 0, 0, 0, 0, 11, 11, 11, 11, 0, 0, 0, 0,
 	0, 0, 0, 0, 0, 0, 11, 0, 0, 0, 0, 11, 0, 0, 0,
 	0, 0, 0, 0, 0, 11, 0, 0, 0, 0, 0, 0, 11, 0, 0,
@@ -34,7 +37,7 @@ var testInvertPGM = []uint8{
 	0, 0, 0, 0, 11, 11, 11, 11, 11, 11, 0, 0, 0, 0, 0,
 }
 
-var testFlipPGM = []uint8{
+var testFlipPGM = []uint16{
 	11, 11, 11, 11, 0, 0, 0, 0, 11, 11, 11, 11, 11, 11, 11,
 	11, 11, 11, 0, 11, 11, 11, 11, 0, 11, 11, 11, 11, 11, 11,
 	11, 11, 0, 11, 11, 11, 11, 11, 11, 0, 11, 11, 11, 11, 11,
@@ -52,7 +55,7 @@ var testFlipPGM = []uint8{
 	11, 11, 11, 11, 11, 0, 0, 0, 0, 0, 0, 11, 11, 11, 11,
 }
 
-var testFlopPGM = []uint8{
+var testFlopPGM = []uint16{
 	11, 11, 11, 11, 0, 0, 0, 0, 0, 0, 11, 11, 11, 11, 11,
 	11, 11, 0, 0, 7, 7, 7, 7, 7, 0, 0, 11, 11, 11, 11,
 	11, 0, 0, 11, 11, 11, 11, 11, 11, 11, 11, 0, 11, 11, 11,
@@ -70,7 +73,7 @@ var testFlopPGM = []uint8{
 	11, 11, 11, 11, 11, 11, 11, 0, 0, 0, 0, 11, 11, 11, 11,
 }
 
-var testRotate90PGM = []uint8{
+var testRotate90PGM = []uint16{
 	11, 11, 11, 11, 0, 0, 0, 0, 0, 11, 11, 11, 11, 11, 11,
 	11, 11, 0, 0, 7, 7, 7, 7, 0, 11, 11, 11, 11, 11, 11,
 	11, 0, 0, 11, 11, 11, 11, 0, 11, 11, 11, 11, 11, 11, 11,
@@ -108,34 +111,33 @@ func TestReadPGM(t *testing.T) {
 	for i := 0; i < imageWidth*imageHeight; i++ {
 		x := i % imageWidth
 		y := i / imageWidth
-		if pgm.data[y][x] != testData[i] {
+		if pgm.At(x, y) != testData[i] {
 			t.Errorf("Pixel at (%d, %d) not read correctly", x, y)
 		}
 	}
-	/*Ici aussi j'ai pas réussi le P5 et je l'ai mis en commentaire aussi*/
-	/*pgm, err = ReadPGM("./testImages/pgm/testP5.pgm")
+	pgm, err = ReadPGM("./testImages/pgm/testP5.pgm")
 	if err != nil {
 		t.Error(err)
 	}
 	if pgm.magicNumber != "P5" {
 		t.Error("Magic number not read correctly")
-	}*/
-	// if pgm.width != imagePGMWidth {
-	// 	t.Error("Width not read correctly")
-	// }
-	// if pgm.height != imagePGMHeight {
-	// 	t.Error("Height not read correctly")
-	// }
-	// if pgm.max != imagePGMMax {
-	// 	t.Error("Max value not read correctly")
-	// }
-	// for i := 0; i < imageWidth*imageHeight; i++ {
-	// 	x := i % imageWidth
-	// 	y := i / imageWidth
-	// 	if pgm.data[y][x] != testData[i] {
-	// 		t.Errorf("Pixel at (%d, %d) not read correctly", x, y)
-	// 	}
-	// }
+	}
+	if pgm.width != imagePGMWidth {
+		t.Error("Width not read correctly")
+	}
+	if pgm.height != imagePGMHeight {
+		t.Error("Height not read correctly")
+	}
+	if pgm.max != imagePGMMax {
+		t.Error("Max value not read correctly")
+	}
+	for i := 0; i < imageWidth*imageHeight; i++ {
+		x := i % imageWidth
+		y := i / imageWidth
+		if pgm.At(x, y) != testData[i] {
+			t.Errorf("Pixel at (%d, %d) not read correctly", x, y)
+		}
+	}
 }
 
 func TestSizePGM(t *testing.T) {
@@ -202,11 +204,11 @@ func TestSavePGM(t *testing.T) {
 	for i := 0; i < imageWidth*imageHeight; i++ {
 		x := i % imageWidth
 		y := i / imageWidth
-		if pgm.data[y][x] != testData[i] {
+		if pgm.At(x, y) != testData[i] {
 			t.Errorf("Pixel at (%d, %d) not read correctly", x, y)
 		}
 	}
-	/*pgm, err = ReadPGM("./testImages/pgm/testP5.pgm")
+	pgm, err = ReadPGM("./testImages/pgm/testP5.pgm")
 	if err != nil {
 		t.Error(err)
 	}
@@ -221,7 +223,18 @@ func TestSavePGM(t *testing.T) {
 	}
 	if pgm.magicNumber != "P5" {
 		t.Error("Magic number not read correctly")
-	}*/
+	}
+	original, err := os.ReadFile("./testImages/pgm/testP5.pgm")
+	if err != nil {
+		t.Error(err)
+	}
+	saved, err := os.ReadFile("./testImages/pgm/testP5a.pgm")
+	if err != nil {
+		t.Error(err)
+	}
+	if !bytes.Equal(original, saved) {
+		t.Error("P5 file not saved byte-for-byte")
+	}
 	if pgm.width != imagePGMWidth {
 		t.Error("Width not read correctly")
 	}
@@ -234,7 +247,7 @@ func TestSavePGM(t *testing.T) {
 	for i := 0; i < imageWidth*imageHeight; i++ {
 		x := i % imageWidth
 		y := i / imageWidth
-		if pgm.data[y][x] != testData[i] {
+		if pgm.At(x, y) != testData[i] {
 			t.Errorf("Pixel at (%d, %d) not read correctly", x, y)
 		}
 	}
@@ -243,10 +256,10 @@ func TestSavePGM(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	/*err = os.Remove("./testImages/pgm/testP5a.pgm")
+	err = os.Remove("./testImages/pgm/testP5a.pgm")
 	if err != nil {
 		t.Error(err)
-	}*/
+	}
 }
 
 func TestInvertPGM(t *testing.T) {
@@ -258,7 +271,7 @@ func TestInvertPGM(t *testing.T) {
 	for i := 0; i < imageWidth*imageHeight; i++ {
 		x := i % imageWidth
 		y := i / imageWidth
-		if pgm.data[y][x] != testInvertPGM[i] {
+		if pgm.At(x, y) != testInvertPGM[i] {
 			t.Errorf("Pixel at (%d, %d) not read correctly", x, y)
 		}
 	}
@@ -273,7 +286,7 @@ func TestFlipPGM(t *testing.T) {
 	for i := 0; i < imageWidth*imageHeight; i++ {
 		x := i % imageWidth
 		y := i / imageWidth
-		if pgm.data[y][x] != testFlipPGM[i] {
+		if pgm.At(x, y) != testFlipPGM[i] {
 			t.Errorf("Pixel at (%d, %d) not read correctly", x, y)
 		}
 	}
@@ -288,7 +301,7 @@ func TestFlopPGM(t *testing.T) {
 	for i := 0; i < imageWidth*imageHeight; i++ {
 		x := i % imageWidth
 		y := i / imageWidth
-		if pgm.data[y][x] != testFlopPGM[i] {
+		if pgm.At(x, y) != testFlopPGM[i] {
 			t.Errorf("Pixel at (%d, %d) not read correctly", x, y)
 		}
 	}
@@ -303,7 +316,7 @@ func TestRotate90CWPGM(t *testing.T) {
 	for i := 0; i < imageWidth*imageHeight; i++ {
 		x := i % imageWidth
 		y := i / imageWidth
-		if pgm.data[y][x] != testRotate90PGM[i] {
+		if pgm.At(x, y) != testRotate90PGM[i] {
 			t.Errorf("Pixel at (%d, %d) not read correctly", x, y)
 		}
 	}
@@ -333,8 +346,34 @@ func TestSetMaxValuePGM(t *testing.T) {
 	for i := 0; i < imageWidth*imageHeight; i++ {
 		x := i % imageWidth
 		y := i / imageWidth
-		if pgm.data[y][x] != testData[i]*uint8(5)/oldMax {
-			t.Errorf("Pixel at (%d, %d) not read correctly, expected %d, got %d", x, y, uint8(float64(testData[i])*float64(5)/float64(oldMax)), pgm.data[y][x])
+		/*Samples are rescaled to the nearest value, like the image adapters do*/
+		expected := (testData[i]*5 + oldMax/2) / oldMax
+		if pgm.At(x, y) != expected {
+			t.Errorf("Pixel at (%d, %d) not read correctly, expected %d, got %d", x, y, expected, pgm.At(x, y))
+		}
+	}
+}
+
+func TestZeroMaxValue(t *testing.T) {
+	/*0 is not a valid max value: it is taken as 1, so that rescaling and decoding still work*/
+	pgm := NewPGM(2, 1, 0)
+	ppm := NewPPM(2, 1, 0)
+	if pgm.max != 1 || ppm.max != 1 || NewPAM(2, 1, 1, 0, "GRAYSCALE").MaxValue() != 1 {
+		t.Fatalf("Max value 0 kept: %d, %d", pgm.max, ppm.max)
+	}
+	pgm.Set(1, 0, 1)
+	pgm.SetMaxValue(0)
+	ppm.SetMaxValue(0)
+	if pgm.max != 1 || ppm.max != 1 || pgm.At(1, 0) != 1 {
+		t.Errorf("SetMaxValue(0) gave max %d, %d and sample %d", pgm.max, ppm.max, pgm.At(1, 0))
+	}
+	for _, img := range []interface{ Encode(w io.Writer) error }{pgm, ppm} {
+		var buffer bytes.Buffer
+		if err := img.Encode(&buffer); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Decode(&buffer); err != nil {
+			t.Errorf("Encoded image not decoded: %v", err)
 		}
 	}
 }
@@ -357,8 +396,142 @@ func TestToPBM(t *testing.T) {
 	for i := 0; i < imageWidth*imageHeight; i++ {
 		x := i % imageWidth
 		y := i / imageWidth
-		if pbm.data[y][x] != (testData[i] > uint8(pgm.max)/2) {
+		/*A set bit is black, so samples darker than half max become set*/
+		if pbm.At(x, y) != (int(testData[i])*2 < int(pgm.max)) {
 			t.Errorf("Pixel at (%d, %d) not read correctly", x, y)
 		}
 	}
 }
+
+func TestSave16BitPGM(t *testing.T) {
+	original := []byte("P5\n3 1\n65535\n\x00\x00\x03\xff\xff\xff")
+	filename := t.TempDir() + "/test16.pgm"
+	err := os.WriteFile(filename, original, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pgm, err := ReadPGM(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pgm.max != 65535 {
+		t.Error("Max value not read correctly")
+	}
+	if pgm.At(0, 0) != 0 || pgm.At(1, 0) != 1023 || pgm.At(2, 0) != 65535 {
+		t.Errorf("16-bit samples not read correctly: %v", pgm.Row(0))
+	}
+	err = pgm.Save(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(original, saved) {
+		t.Error("16-bit P5 file not saved byte-for-byte")
+	}
+	pgm.Invert()
+	if pgm.At(1, 0) != 65535-1023 {
+		t.Error("16-bit sample not inverted correctly")
+	}
+	pgm.SetMaxValue(1023)
+	if pgm.max != 1023 || pgm.At(0, 0) != 1023 || pgm.At(2, 0) != 0 {
+		t.Errorf("16-bit samples not rescaled correctly: %v", pgm.Row(0))
+	}
+}
+
+func TestDecodeEncodePGM(t *testing.T) {
+	original, err := os.ReadFile("./testImages/pgm/testP5.pgm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pgm, err := DecodePGM(bytes.NewReader(original))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < imageWidth*imageHeight; i++ {
+		x := i % imageWidth
+		y := i / imageWidth
+		if pgm.At(x, y) != testData[i] {
+			t.Errorf("Pixel at (%d, %d) not read correctly", x, y)
+		}
+	}
+	var buffer bytes.Buffer
+	err = pgm.Encode(&buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buffer.Bytes(), original) {
+		t.Error("Encoded data differs from the original")
+	}
+}
+
+func TestPGMRotate90CWTiled(t *testing.T) {
+	/*Larger than one rotation tile on both sides, and not square*/
+	pgm := NewPGM(70, 40, 65535)
+	for i := range pgm.Pix {
+		pgm.Pix[i] = uint16(i)
+	}
+	rotated := NewPGM(70, 40, 65535)
+	copy(rotated.Pix, pgm.Pix)
+	rotated.Rotate90CW()
+	if width, height := rotated.Size(); width != 40 || height != 70 || rotated.Stride != 40 {
+		t.Fatalf("Wrong size %d x %d, stride %d", width, height, rotated.Stride)
+	}
+	for y := 0; y < 40; y++ {
+		for x := 0; x < 70; x++ {
+			if rotated.At(40-y-1, x) != pgm.At(x, y) {
+				t.Fatalf("Pixel at (%d, %d) not rotated correctly", x, y)
+			}
+		}
+	}
+	if &rotated.Row(3)[0] != &rotated.Pix[3*rotated.Stride] {
+		t.Error("Row should share Pix")
+	}
+}
+
+func TestCommentsPGM(t *testing.T) {
+	input := "P2\n# creator: scanner\n2 1 # size\n#source: /tmp/a.pgm\n255\n1 # not a header comment\n2\n"
+	pgm, err := DecodePGM(bytes.NewReader([]byte(input)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"creator: scanner", "size", "source: /tmp/a.pgm"}
+	if !reflect.DeepEqual(pgm.Comments, expected) {
+		t.Fatalf("Wrong comments %q", pgm.Comments)
+	}
+
+	pgm.Flip()
+	pgm.Rotate90CW()
+	pgm.AddComment("edited\nby hand")
+	var buffer bytes.Buffer
+	if err := pgm.Encode(&buffer); err != nil {
+		t.Fatal(err)
+	}
+	if want := "P2\n# creator: scanner\n# size\n# source: /tmp/a.pgm\n# edited\n# by hand\n1 2\n255\n"; !bytes.HasPrefix(buffer.Bytes(), []byte(want)) {
+		t.Errorf("Wrong header %q", buffer.String())
+	}
+	decoded, err := DecodePGM(&buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.Comments) != 5 {
+		t.Errorf("Comments not read back: %q", decoded.Comments)
+	}
+
+	pbm := pgm.ToPBM()
+	if !reflect.DeepEqual(pbm.Comments, pgm.Comments) {
+		t.Errorf("ToPBM lost the comments: %q", pbm.Comments)
+	}
+	pbm.SetComments("only")
+	if len(pgm.Comments) != 4 || !reflect.DeepEqual(pbm.Comments, []string{"only"}) {
+		t.Errorf("Comments are shared: %q, %q", pgm.Comments, pbm.Comments)
+	}
+	pgm.SetComments()
+	buffer.Reset()
+	pgm.Encode(&buffer)
+	if !bytes.HasPrefix(buffer.Bytes(), []byte("P2\n1 2\n")) {
+		t.Errorf("Comments not removed: %q", buffer.String())
+	}
+}
@@ -0,0 +1,56 @@
+package Netpbm
+
+import (
+	"bufio"
+	"strconv"
+)
+
+/*maxLineLength is the longest line the spec allows in a plain raster.*/
+const maxLineLength = 70
+
+/*
+plainWriter writes the raster of a plain format. Every row starts on a new
+line and is wrapped before it passes maxLineLength, with no trailing
+whitespace. Samples are separated by one space, P1 bits by nothing.
+*/
+type plainWriter struct {
+	writer *bufio.Writer
+	line   []byte
+}
+
+func newPlainWriter(writer *bufio.Writer) *plainWriter {
+	return &plainWriter{writer: writer, line: make([]byte, 0, maxLineLength+8)}
+}
+
+func (p *plainWriter) sample(value uint16) {
+	start := len(p.line)
+	if start > 0 {
+		p.line = append(p.line, ' ')
+	}
+	p.line = strconv.AppendUint(p.line, uint64(value), 10)
+	if len(p.line) > maxLineLength {
+		/*The sample goes on the next line, without the space*/
+		p.writer.Write(p.line[:start])
+		p.writer.WriteByte('\n')
+		p.line = append(p.line[:0], p.line[start+1:]...)
+	}
+}
+
+func (p *plainWriter) bit(set bool) {
+	if len(p.line) == maxLineLength {
+		p.endLine()
+	}
+	if set {
+		p.line = append(p.line, '1')
+	} else {
+		p.line = append(p.line, '0')
+	}
+}
+
+/*endLine ends the current row; a write error also comes back from the final Flush.*/
+func (p *plainWriter) endLine() error {
+	p.line = append(p.line, '\n')
+	_, err := p.writer.Write(p.line)
+	p.line = p.line[:0]
+	return err
+}
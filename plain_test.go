@@ -0,0 +1,82 @@
+package Netpbm
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+/*checkPlainLines checks the spec line rules on everything after the header lines.*/
+func checkPlainLines(t *testing.T, output string, headerLines int) []string {
+	t.Helper()
+	lines := strings.Split(strings.TrimSuffix(output, "\n"), "\n")[headerLines:]
+	for _, line := range lines {
+		if len(line) > maxLineLength {
+			t.Errorf("Line of %d characters: %q", len(line), line)
+		}
+		if strings.TrimSpace(line) != line {
+			t.Errorf("Whitespace around %q", line)
+		}
+	}
+	return lines
+}
+
+func TestPlainLineLength(t *testing.T) {
+	pgm := NewPGM(40, 2, 65535)
+	for i := range pgm.Pix {
+		pgm.Pix[i] = uint16(i * 1000)
+	}
+	var buffer bytes.Buffer
+	if err := pgm.Encode(&buffer); err != nil {
+		t.Fatal(err)
+	}
+	lines := checkPlainLines(t, buffer.String(), 3)
+	if len(lines) < 4 || !strings.HasPrefix(lines[0], "0 1000 2000 ") {
+		t.Errorf("Rows not wrapped: %q", lines)
+	}
+	decoded, err := DecodePGM(&buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range pgm.Pix {
+		if decoded.Pix[i] != pgm.Pix[i] {
+			t.Fatalf("Sample %d is %d, expected %d", i, decoded.Pix[i], pgm.Pix[i])
+		}
+	}
+
+	ppm := NewPPM(30, 1, 255)
+	for i := range ppm.Pix {
+		ppm.Pix[i] = Pixel{255, uint16(i), 7}
+	}
+	buffer.Reset()
+	ppm.Encode(&buffer)
+	checkPlainLines(t, buffer.String(), 3)
+}
+
+func TestPlainPBMCompact(t *testing.T) {
+	pbm := NewPBM(75, 2)
+	pbm.Set(0, 0, true)
+	pbm.Set(74, 1, true)
+	var buffer bytes.Buffer
+	if err := pbm.Encode(&buffer); err != nil {
+		t.Fatal(err)
+	}
+	lines := checkPlainLines(t, buffer.String(), 2)
+	expected := []string{"1" + strings.Repeat("0", 69), "00000", strings.Repeat("0", 70), "00001"}
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Wrong raster %q", lines)
+	}
+
+	buffer.Reset()
+	rw, err := NewRowWriter(&buffer, Header{MagicNumber: "P1", Width: 3, Height: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rw.WriteRow([]uint16{1, 0, 1})
+	if err := rw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if buffer.String() != "P1\n3 1\n101\n" {
+		t.Errorf("Wrong row writer output %q", buffer.String())
+	}
+}
@@ -2,224 +2,282 @@ package Netpbm
 
 import (
 	"bufio"
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"math"
 	"os"
-	"strconv"
-	"strings"
+	"sort"
 )
 
 type Pixel struct {
-	R, G, B uint8
+	R, G, B uint16
 }
 
+/*
+The Raster holds the pixels and brings the pixel access and geometry.
+Comments are the header comments, without the '#'.
+*/
 type PPM struct {
-	data          [][]Pixel
-	width, height int
-	magicNumber   string
-	max           uint8
+	Raster[Pixel]
+	magicNumber string
+	max         uint16
+	Comments    []string
 }
 
-type Point struct {
-	X, Y int
+// NewPPM returns a plain (P3) pixmap of the given size with every pixel black; a max value of 0 is taken as 1.
+func NewPPM(width, height int, max uint16) *PPM {
+	return &PPM{Raster: *NewRaster[Pixel](width, height), magicNumber: "P3", max: validMax(max)}
 }
 
-func (ppm *PPM) Size() (int, int) {
-	return ppm.width, ppm.height
+type Point struct {
+	X, Y int
 }
 
-func ReadPPM(filename string) (*PPM, error) {
+func ReadPPM(filename string, opts ...DecodeOption) (*PPM, error) {
 	file, err := os.Open(filename)
 	if err != nil {
 		return nil, err
 	}
 	defer file.Close()
+	return DecodePPM(file, fileOptions(filename, opts)...)
+}
 
-	scanner := bufio.NewScanner(file)
+// ReadPPMFS is ReadPPM for a file of fsys, such as an embed.FS.
+func ReadPPMFS(fsys fs.FS, name string, opts ...DecodeOption) (*PPM, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return DecodePPM(file, fileOptions(name, opts)...)
+}
 
-	ppm := &PPM{}
+// DecodePPM reads a P3 or P6 pixmap from r.
+func DecodePPM(r io.Reader, opts ...DecodeOption) (*PPM, error) {
+	tok := newTokenizer(r, opts...)
 
 	/* ce bloc nous permet de lire et analyser les informations d'en-tête*/
-	if !scanner.Scan() {
-		return nil, errors.New("EOF while reading magic number")
+	magicNumber, err := tok.nextMagic()
+	if err != nil {
+		return nil, err
 	}
-	ppm.magicNumber = scanner.Text()
 
-	if ppm.magicNumber != "P3" {
-		return nil, errors.New("Unsupported PPM format. Only P3 is supported.")
+	if magicNumber != "P3" && magicNumber != "P6" {
+		return nil, tok.tokenError("P3 or P6", fmt.Sprintf("%q", magicNumber), ErrBadMagic)
 	}
+	return decodePPM(tok, magicNumber)
+}
+
+/*decodePPM reads what follows the magic number, it is shared with Decode.*/
+func decodePPM(tok *tokenizer, magicNumber string) (*PPM, error) {
+	ppm := &PPM{magicNumber: magicNumber}
+	var err error
 
-	if !scanner.Scan() {
-		return nil, errors.New("EOF while reading width and height")
+	if ppm.width, err = tok.nextDimension("width"); err != nil {
+		return nil, err
+	}
+	if ppm.height, err = tok.nextDimension("height"); err != nil {
+		return nil, err
+	}
+	if err = tok.checkSize(ppm.width, ppm.height, 6); err != nil {
+		return nil, err
 	}
-	fmt.Sscanf(scanner.Text(), "%d %d", &ppm.width, &ppm.height)
 
-	if !scanner.Scan() {
-		return nil, errors.New("EOF while reading max value")
+	/* P6 data starts right after the whitespace that ends the max value*/
+	if ppm.max, err = tok.nextMaxValue(); err != nil {
+		return nil, err
 	}
-	max, err := strconv.Atoi(scanner.Text())
-	if err != nil {
-		return nil, fmt.Errorf("Error converting max value to integer: %v", err)
-	}
-	ppm.max = uint8(max)
-
-	ppm.data = make([][]Pixel, ppm.height)
-	for i := range ppm.data {
-		ppm.data[i] = make([]Pixel, ppm.width)
-		for j := 0; j < ppm.width; j++ {
-			/*Exit if EOF is detected during pixel reading*/
-			if !scanner.Scan() {
-				if scanner.Err() == nil {
-					return nil, fmt.Errorf("Unexpected EOF while reading pixel data (row: %d, column: %d)", i, j)
+	ppm.Comments = tok.headerComments()
+
+	ppm.Raster = *NewRaster[Pixel](ppm.width, ppm.height)
+	if ppm.magicNumber == "P6" {
+		/* raw rows of interleaved R, G, B samples, two bytes big-endian when max is above 255*/
+		size := sampleSize(ppm.max)
+		buffer := make([]byte, ppm.width*3*size)
+		samples := make([]uint16, ppm.width*3)
+		for i := 0; i < ppm.height; i++ {
+			if err := tok.readFull(buffer, fmt.Sprintf("row %d", i+1)); err != nil {
+				return nil, err
+			}
+			for k := range samples {
+				if size == 2 {
+					samples[k] = binary.BigEndian.Uint16(buffer[k*2:])
+				} else {
+					samples[k] = uint16(buffer[k])
+				}
+				if samples[k], err = tok.checkRaw(len(buffer)-k*size, samples[k], ppm.max); err != nil {
+					return nil, err
 				}
-				return nil, scanner.Err()
 			}
-
-			line := scanner.Text()
-			values := strings.Fields(line)
-
-			// Ensure that there are enough values to read
-			if len(values) < 3 {
-				return nil, fmt.Errorf("Insufficient values for a pixel (row: %d, column: %d)", i, j)
+			row := ppm.Row(i)
+			for j := range row {
+				row[j] = Pixel{samples[j*3], samples[j*3+1], samples[j*3+2]}
 			}
-
-			for k := 0; k < 3; k++ {
-				r, err := strconv.Atoi(values[k])
+		}
+		return ppm, nil
+	}
+
+	/* a P3 raster is a stream of width*height*3 samples, whatever the line layout*/
+	expected := ppm.width * ppm.height * 3
+	count := 0
+	for i := 0; i < ppm.height; i++ {
+		row := ppm.Row(i)
+		for j := range row {
+			var values [3]uint16
+			for k := range values {
+				value, err := tok.nextSample(ppm.max)
+				/*If the input ends early, say how many samples were found*/
+				var parseErr *ParseError
+				if errors.As(err, &parseErr) && parseErr.Err == ErrTruncated {
+					parseErr.Expected, parseErr.Found = fmt.Sprintf("%d samples", expected), fmt.Sprintf("%d", count)
+				}
 				if err != nil {
-					return nil, fmt.Errorf("Error converting pixel value to integer (row: %d, column: %d): %v", i, j, err)
+					return nil, err
 				}
-				ppm.data[i][j] = Pixel{uint8(r), uint8(r), uint8(r)} // Assuming grayscale, adjust if necessary
-				j++
+				values[k] = value
+				count++
 			}
+			row[j] = Pixel{values[0], values[1], values[2]}
 		}
 	}
+	if err := tok.checkEnd(expected, false); err != nil {
+		return nil, err
+	}
 
 	return ppm, nil
 }
 
-func (ppm *PPM) At(x, y int) Pixel {
-	return ppm.data[y][x]
+// Format returns the magic number, P3 or P6.
+func (ppm *PPM) Format() string {
+	return ppm.magicNumber
 }
 
-func (ppm *PPM) Set(x, y int, value Pixel) {
-	ppm.data[y][x] = value
+func (ppm *PPM) MaxValue() uint16 {
+	return ppm.max
 }
 
-func (ppm *PPM) Save(filename string) error {
-	file, err := os.Create(filename)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	writer := bufio.NewWriter(file)
+// Clone returns a deep copy of the pixmap as a *PPM.
+func (ppm *PPM) Clone() Image {
+	clone := *ppm
+	clone.Raster = *ppm.Raster.Clone()
+	clone.Comments = append([]string(nil), ppm.Comments...)
+	return &clone
+}
 
-	fmt.Fprintf(writer, "%s\n", ppm.magicNumber)
+func (ppm *PPM) Save(filename string, opts ...SaveOption) error {
+	return saveFile(filename, ppm.Encode, opts)
+}
 
-	fmt.Fprintf(writer, "%d %d %d\n", ppm.width, ppm.height, ppm.max)
+// Encode writes the pixmap to w, raw if the magic number is P6 and plain otherwise.
+func (ppm *PPM) Encode(w io.Writer) error {
+	writer := bufio.NewWriter(w)
 
-	for _, row := range ppm.data {
-		for _, pixel := range row {
-			fmt.Fprintf(writer, "%d %d %d ", pixel.R, pixel.G, pixel.B)
+	fmt.Fprintf(writer, "%s\n", ppm.magicNumber)
+	writeComments(writer, ppm.Comments)
+
+	fmt.Fprintf(writer, "%d %d\n%d\n", ppm.width, ppm.height, ppm.max)
+
+	if ppm.magicNumber == "P6" {
+		size := sampleSize(ppm.max)
+		buffer := make([]byte, ppm.width*3*size)
+		for y := 0; y < ppm.height; y++ {
+			for j, pixel := range ppm.Row(y) {
+				for k, value := range [3]uint16{pixel.R, pixel.G, pixel.B} {
+					if size == 2 {
+						binary.BigEndian.PutUint16(buffer[(j*3+k)*2:], value)
+					} else {
+						buffer[j*3+k] = uint8(value)
+					}
+				}
+			}
+			if _, err := writer.Write(buffer); err != nil {
+				return err
+			}
 		}
-		fmt.Fprintln(writer)
+		return writer.Flush()
 	}
 
-	return writer.Flush()
-}
-
-func (ppm *PPM) Invert() {
+	plain := newPlainWriter(writer)
 	for y := 0; y < ppm.height; y++ {
-		for x := 0; x < ppm.width; x++ {
-			ppm.data[y][x].R = uint8(ppm.max) - ppm.data[y][x].R
-			ppm.data[y][x].G = uint8(ppm.max) - ppm.data[y][x].G
-			ppm.data[y][x].B = uint8(ppm.max) - ppm.data[y][x].B
+		for _, pixel := range ppm.Row(y) {
+			plain.sample(pixel.R)
+			plain.sample(pixel.G)
+			plain.sample(pixel.B)
 		}
+		plain.endLine()
 	}
-}
 
-func (ppm *PPM) Flip() {
-	for y := 0; y < ppm.height; y++ {
-		for x := 0; x < ppm.width/2; x++ {
-			ppm.data[y][x], ppm.data[y][ppm.width-x-1] = ppm.data[y][ppm.width-x-1], ppm.data[y][x]
-		}
-	}
+	return writer.Flush()
 }
 
-func (ppm *PPM) Flop() {
-	for y := 0; y < ppm.height/2; y++ {
-		ppm.data[y], ppm.data[ppm.height-y-1] = ppm.data[ppm.height-y-1], ppm.data[y]
-	}
+func (ppm *PPM) Invert() {
+	ppm.Map(func(pixel Pixel) Pixel {
+		return Pixel{ppm.max - pixel.R, ppm.max - pixel.G, ppm.max - pixel.B}
+	})
 }
 
 func (ppm *PPM) SetMagicNumber(magicNumber string) {
 	ppm.magicNumber = magicNumber
 }
 
-func (ppm *PPM) SetMaxValue(maxValue uint8) {
-	ppm.max = uint8(maxValue)
-
+// AddComment appends a comment to the header.
+func (ppm *PPM) AddComment(comment string) {
+	ppm.Comments = append(ppm.Comments, comment)
 }
 
-func (ppm *PPM) Rotate90CW() {
-	newData := make([][]Pixel, ppm.width)
-	for i := 0; i < ppm.width; i++ {
-		newData[i] = make([]Pixel, ppm.height)
-	}
+// SetComments replaces the header comments, none removes them all.
+func (ppm *PPM) SetComments(comments ...string) {
+	ppm.Comments = append([]string(nil), comments...)
+}
 
-	for y := 0; y < ppm.height; y++ {
-		for x := 0; x < ppm.width; x++ {
-			newData[x][ppm.height-y-1] = ppm.data[y][x]
-		}
-	}
+// SetMaxValue rescales every sample to the new max value, 0 being taken as 1.
+func (ppm *PPM) SetMaxValue(maxValue uint16) {
+	maxValue = validMax(maxValue)
+	oldMax := ppm.max
+	ppm.max = maxValue
 
-	ppm.data = newData
-	ppm.width, ppm.height = ppm.height, ppm.width
+	from, to := uint32(oldMax), uint32(maxValue)
+	ppm.Map(func(pixel Pixel) Pixel {
+		return Pixel{uint16(scale(uint32(pixel.R), from, to)), uint16(scale(uint32(pixel.G), from, to)), uint16(scale(uint32(pixel.B), from, to))}
+	})
 }
 
 func (ppm *PPM) ToPGM() *PGM {
-	pgmData := make([][]uint8, ppm.height)
+	pgm := NewPGM(ppm.width, ppm.height, ppm.max)
+	pgm.SetComments(ppm.Comments...)
 	for y := 0; y < ppm.height; y++ {
-		pgmData[y] = make([]uint8, ppm.width)
-		for x := 0; x < ppm.width; x++ {
-			pgmData[y][x] = uint8((int(ppm.data[y][x].R) + int(ppm.data[y][x].G) + int(ppm.data[y][x].B)) / 3)
+		pgmRow := pgm.Row(y)
+		for x, pixel := range ppm.Row(y) {
+			pgmRow[x] = uint16((uint32(pixel.R) + uint32(pixel.G) + uint32(pixel.B)) / 3)
 		}
 	}
 
-	return &PGM{
-		data:        pgmData,
-		width:       ppm.width,
-		height:      ppm.height,
-		magicNumber: "P2",
-		max:         uint8(ppm.max),
-	}
+	return pgm
 }
 
+// ToPBM sets the pixels darker than half max, a set bit being black.
 func (ppm *PPM) ToPBM() *PBM {
 
-	pbmData := make([][]bool, ppm.height)
+	pbm := NewPBM(ppm.width, ppm.height)
+	pbm.SetComments(ppm.Comments...)
 
 	for y := 0; y < ppm.height; y++ {
 
-		pbmData[y] = make([]bool, ppm.width)
+		pbmRow := pbm.Row(y)
 
-		for x := 0; x < ppm.width; x++ {
+		for x, pixel := range ppm.Row(y) {
 			// And Here I Calculate the grayscale value by averaging RGB values
-			grayValue := (int(ppm.data[y][x].R) + int(ppm.data[y][x].G) + int(ppm.data[y][x].B)) / 3
+			grayValue := (uint32(pixel.R) + uint32(pixel.G) + uint32(pixel.B)) / 3
 
 			// Here I Set the corresponding PBM pixel value based on the grayscale value
-			pbmData[y][x] = grayValue < int(ppm.max)/2
+			pbmRow[x] = grayValue*2 < uint32(ppm.max)
 		}
 	}
 
-	// I Create a new PBM object with the converted data
-	return &PBM{
-		data:        pbmData,
-		width:       ppm.width,
-		height:      ppm.height,
-		magicNumber: "P1",
-	}
+	return pbm
 }
 
 func (ppm *PPM) DrawLine(p1, p2 Point, color Pixel) {
@@ -231,7 +289,7 @@ func (ppm *PPM) DrawLine(p1, p2 Point, color Pixel) {
 		return
 	}
 
-	steps := int(math.Max(math.Abs(float64(dx)), math.Abs(float64(dy)))) + 1
+	steps := int(math.Max(math.Abs(float64(dx)), math.Abs(float64(dy))))
 	xIncrement := float64(dx) / float64(steps)
 	yIncrement := float64(dy) / float64(steps)
 
@@ -244,38 +302,44 @@ func (ppm *PPM) DrawLine(p1, p2 Point, color Pixel) {
 	}
 }
 
+// DrawRectangle outlines the rectangle from p1 to p1 + (width, height), both corners included.
 func (ppm *PPM) DrawRectangle(p1 Point, width, height int, color Pixel) {
-	p2 := Point{p1.X + width - 1, p1.Y}
-	p3 := Point{p1.X, p1.Y + height - 1}
-	p4 := Point{p1.X + width - 1, p1.Y + height - 1}
+	p2 := Point{p1.X + width, p1.Y}
+	p3 := Point{p1.X, p1.Y + height}
+	p4 := Point{p1.X + width, p1.Y + height}
 
 	ppm.DrawLine(p1, p2, color)
 	ppm.DrawLine(p2, p4, color)
 	ppm.DrawLine(p4, p3, color)
 	ppm.DrawLine(p3, p1, color)
 }
+
+// DrawFilledRectangle fills the same pixels DrawRectangle outlines.
 func (ppm *PPM) DrawFilledRectangle(p1 Point, width, height int, color Pixel) {
-	for y := p1.Y; y < p1.Y+height; y++ {
-		for x := p1.X; x < p1.X+width; x++ {
+	for y := p1.Y; y <= p1.Y+height; y++ {
+		for x := p1.X; x <= p1.X+width; x++ {
 			ppm.Set(x, y, color)
 		}
 	}
 }
 
+// DrawCircle sets the pixels whose distance to the center is from radius-1 up to, but not including, radius.
 func (ppm *PPM) DrawCircle(center Point, radius int, color Pixel) {
 	for y := -radius; y <= radius; y++ {
 		for x := -radius; x <= radius; x++ {
-			if x*x+y*y <= radius*radius {
+			distance := math.Sqrt(float64(x*x + y*y))
+			if distance < float64(radius) && distance >= float64(radius)-1 {
 				ppm.Set(center.X+x, center.Y+y, color)
 			}
 		}
 	}
 }
 
+// DrawFilledCircle sets the pixels whose distance to the center is less than radius.
 func (ppm *PPM) DrawFilledCircle(center Point, radius int, color Pixel) {
 	for y := -radius; y <= radius; y++ {
 		for x := -radius; x <= radius; x++ {
-			if x*x+y*y <= radius*radius {
+			if x*x+y*y < radius*radius {
 				ppm.Set(center.X+x, center.Y+y, color)
 			}
 		}
@@ -363,31 +427,30 @@ func (ppm *PPM) DrawFilledPolygon(points []Point, color Pixel) error {
 		} else {
 			start, end = p2, p1
 		}
+		/*Horizontal edges are drawn by the outline below*/
+		if start.Y == end.Y {
+			continue
+		}
 
 		slope := float64(end.X-start.X) / float64(end.Y-start.Y)
 
 		x := float64(start.X)
 
-		for y := start.Y; y <= end.Y; y++ {
+		/*The lower end is left out so that a vertex shared by two edges counts once*/
+		for y := start.Y; y < end.Y; y++ {
 			index := y - minY
 			xCoordinates[index] = append(xCoordinates[index], int(x+0.5))
 			x += slope
 		}
 	}
 
-	for i := 0; i < len(xCoordinates); i += 2 {
-		for j := 0; j < len(xCoordinates[i])-1; j += 2 {
-			ppm.DrawLine(Point{xCoordinates[i][j], i + minY},
-				Point{xCoordinates[i][j+1], i + minY}, color)
-		}
-
-		if i+1 < len(xCoordinates) {
-			for j := 0; j < len(xCoordinates[i+1])-1; j += 2 {
-				ppm.DrawLine(Point{xCoordinates[i+1][j], i + minY + 1},
-					Point{xCoordinates[i+1][j+1], i + minY + 1}, color)
-			}
+	for i, xs := range xCoordinates {
+		sort.Ints(xs)
+		for j := 0; j+1 < len(xs); j += 2 {
+			ppm.DrawLine(Point{xs[j], i + minY}, Point{xs[j+1], i + minY}, color)
 		}
 	}
+	ppm.DrawPolygon(points, color)
 
 	return nil
 }
@@ -1,6 +1,8 @@
 package Netpbm
 
 import (
+	"bytes"
+	"errors"
 	"os"
 	"testing"
 )
@@ -281,18 +283,17 @@ func TestReadPPM(t *testing.T) {
 	for i := 0; i < imageWidth*imageHeight; i++ {
 		x := i % imageWidth
 		y := i / imageWidth
-		if ppm.data[y][x] != imagePPMData[i] {
+		if ppm.At(x, y) != imagePPMData[i] {
 			t.Errorf("Pixel at (%d, %d) not read correctly", x, y)
 		}
 	}
-	/*Comme vous pouvez le voir j'ai pas réussi le P6*/
-	/*ppm, err = ReadPPM("./testImages/ppm/testP6.ppm")
+	ppm, err = ReadPPM("./testImages/ppm/testP6.ppm")
 	if err != nil {
 		t.Error(err)
 	}
 	if ppm.magicNumber != "P6" {
 		t.Error("Magic number not read correctly")
-	}*/
+	}
 	if ppm.width != imagePPMWidth {
 		t.Error("Width not read correctly")
 	}
@@ -305,7 +306,7 @@ func TestReadPPM(t *testing.T) {
 	for i := 0; i < imageWidth*imageHeight; i++ {
 		x := i % imageWidth
 		y := i / imageWidth
-		if ppm.data[y][x] != imagePPMData[i] {
+		if ppm.At(x, y) != imagePPMData[i] {
 			t.Errorf("Pixel at (%d, %d) not read correctly", x, y)
 		}
 	}
@@ -377,11 +378,11 @@ func TestPPMSave(t *testing.T) {
 	for i := 0; i < imageWidth*imageHeight; i++ {
 		x := i % imageWidth
 		y := i / imageWidth
-		if ppm.data[y][x] != imagePPMData[i] {
+		if ppm.At(x, y) != imagePPMData[i] {
 			t.Errorf("Pixel at (%d, %d) not read correctly", x, y)
 		}
 	}
-	/*ppm, err = ReadPPM("./testImages/ppm/testP6.ppm")
+	ppm, err = ReadPPM("./testImages/ppm/testP6.ppm")
 	if err != nil {
 		t.Error(err)
 	}
@@ -396,7 +397,7 @@ func TestPPMSave(t *testing.T) {
 	}
 	if ppm.magicNumber != "P6" {
 		t.Error("Magic number not read correctly")
-	}*/
+	}
 	if ppm.width != imagePPMWidth {
 		t.Error("Width not read correctly")
 	}
@@ -409,7 +410,7 @@ func TestPPMSave(t *testing.T) {
 	for i := 0; i < imageWidth*imageHeight; i++ {
 		x := i % imageWidth
 		y := i / imageWidth
-		if ppm.data[y][x] != imagePPMData[i] {
+		if ppm.At(x, y) != imagePPMData[i] {
 			t.Errorf("Pixel at (%d, %d) not read correctly", x, y)
 		}
 	}
@@ -418,10 +419,10 @@ func TestPPMSave(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	/*err = os.Remove("./testImages/ppm/testP6a.ppm")
+	err = os.Remove("./testImages/ppm/testP6a.ppm")
 	if err != nil {
 		t.Error(err)
-	}*/
+	}
 }
 
 func TestPPMInvert(t *testing.T) {
@@ -433,7 +434,7 @@ func TestPPMInvert(t *testing.T) {
 	for i := 0; i < imageWidth*imageHeight; i++ {
 		x := i % imageWidth
 		y := i / imageWidth
-		if ppm.data[y][x] != imagePPMInvert[i] {
+		if ppm.At(x, y) != imagePPMInvert[i] {
 			t.Errorf("Pixel at (%d, %d) not inverted correctly", x, y)
 		}
 	}
@@ -449,7 +450,7 @@ func TestPPMFlip(t *testing.T) {
 	for i := 0; i < imageWidth*imageHeight; i++ {
 		x := i % imageWidth
 		y := i / imageWidth
-		if ppm.data[y][x] != imagePPMFlip[i] {
+		if ppm.At(x, y) != imagePPMFlip[i] {
 			t.Errorf("Pixel at (%d, %d) not flipped correctly", x, y)
 		}
 	}
@@ -464,7 +465,7 @@ func TestPPMFlop(t *testing.T) {
 	for i := 0; i < imageWidth*imageHeight; i++ {
 		x := i % imageWidth
 		y := i / imageWidth
-		if ppm.data[y][x] != imagePPMFlop[i] {
+		if ppm.At(x, y) != imagePPMFlop[i] {
 			t.Errorf("Pixel at (%d, %d) not flopped correctly", x, y)
 		}
 	}
@@ -491,23 +492,26 @@ func TestPPMSetMaxValue(t *testing.T) {
 		t.Error(err)
 	}
 	oldMax := ppm.max
-	ppm.SetMaxValue(128)
-	if ppm.max != 128 {
+	/*Down to 10, 192 and 14 round up to 8 and 1 where truncating would give 7 and 0*/
+	ppm.SetMaxValue(10)
+	if ppm.max != 10 {
 		t.Error("Max value not set correctly")
 	}
 	for i := 0; i < imageWidth*imageHeight; i++ {
 		x := i % imageWidth
 		y := i / imageWidth
-		if ppm.data[y][x].R != uint8(float64(imagePPMData[i].R)*float64(ppm.max)/float64(oldMax)) {
-			t.Errorf("Red value at (%d, %d) not converted correctly wanted %d got %d", x, y, uint8(float64(imagePPMData[i].R)*float64(ppm.max)/float64(oldMax)), ppm.data[y][x].R)
+		expected := Pixel{
+			(imagePPMData[i].R*ppm.max + oldMax/2) / oldMax,
+			(imagePPMData[i].G*ppm.max + oldMax/2) / oldMax,
+			(imagePPMData[i].B*ppm.max + oldMax/2) / oldMax,
 		}
-		if ppm.data[y][x].G != uint8(float64(imagePPMData[i].G)*float64(ppm.max)/float64(oldMax)) {
-			t.Errorf("Green value at (%d, %d) not converted correctly wanted %d got %d", x, y, uint8(float64(imagePPMData[i].G)*float64(ppm.max)/float64(oldMax)), ppm.data[y][x].G)
-		}
-		if ppm.data[y][x].B != uint8(float64(imagePPMData[i].B)*float64(ppm.max)/float64(oldMax)) {
-			t.Errorf("Blue value at (%d, %d) not converted correctly wanted %d got %d", x, y, uint8(float64(imagePPMData[i].B)*float64(ppm.max)/float64(oldMax)), ppm.data[y][x].B)
+		if ppm.At(x, y) != expected {
+			t.Errorf("Pixel at (%d, %d) not converted correctly wanted %v got %v", x, y, expected, ppm.At(x, y))
 		}
 	}
+	if ppm.At(12, 4) != (Pixel{10, 8, 1}) {
+		t.Errorf("Samples not rounded to the nearest value: %v", ppm.At(12, 4))
+	}
 }
 
 func TestPPMRotate90CW(t *testing.T) {
@@ -525,8 +529,8 @@ func TestPPMRotate90CW(t *testing.T) {
 	for i := 0; i < imageWidth*imageHeight; i++ {
 		x := i % imageWidth
 		y := i / imageWidth
-		if ppm.data[y][x] != imagePPMRotate90[i] {
-			t.Errorf("Pixel at (%d, %d) not rotated correctly wanted %v got %v", x, y, imagePPMRotate90[i], ppm.data[x][y])
+		if ppm.At(x, y) != imagePPMRotate90[i] {
+			t.Errorf("Pixel at (%d, %d) not rotated correctly wanted %v got %v", x, y, imagePPMRotate90[i], ppm.At(x, y))
 		}
 	}
 }
@@ -552,8 +556,8 @@ func TestPPMToPGM(t *testing.T) {
 	for i := 0; i < imageWidth*imageHeight; i++ {
 		x := i % imageWidth
 		y := i / imageWidth
-		if pgm.data[y][x] != uint8((int(imagePPMData[i].R)+int(imagePPMData[i].G)+int(imagePPMData[i].B))/3) {
-			t.Errorf("Pixel at (%d, %d) not converted correctly wanted %d got %d", x, y, uint8((int(imagePPMData[i].R)+int(imagePPMData[i].G)+int(imagePPMData[i].B))/3), pgm.data[y][x])
+		if pgm.At(x, y) != uint16((int(imagePPMData[i].R)+int(imagePPMData[i].G)+int(imagePPMData[i].B))/3) {
+			t.Errorf("Pixel at (%d, %d) not converted correctly wanted %d got %d", x, y, uint8((int(imagePPMData[i].R)+int(imagePPMData[i].G)+int(imagePPMData[i].B))/3), pgm.At(x, y))
 		}
 	}
 }
@@ -576,8 +580,10 @@ func TestPPMToPBM(t *testing.T) {
 	for i := 0; i < imageWidth*imageHeight; i++ {
 		x := i % imageWidth
 		y := i / imageWidth
-		if pbm.data[y][x] != (uint8((int(imagePPMData[i].R)+int(imagePPMData[i].G)+int(imagePPMData[i].B))/3) > uint8(ppm.max)/2) {
-			t.Errorf("Pixel at (%d, %d) not converted correctly wanted %t got %t", x, y, uint8((int(imagePPMData[i].R)+int(imagePPMData[i].G)+int(imagePPMData[i].B))/3) > uint8(ppm.max)/2, pbm.data[y][x])
+		/*A set bit is black, so pixels darker than half max become set*/
+		gray := (int(imagePPMData[i].R) + int(imagePPMData[i].G) + int(imagePPMData[i].B)) / 3
+		if pbm.At(x, y) != (gray*2 < int(ppm.max)) {
+			t.Errorf("Pixel at (%d, %d) not converted correctly wanted %t got %t", x, y, gray*2 < int(ppm.max), pbm.At(x, y))
 		}
 	}
 }
@@ -593,8 +599,8 @@ func TestPPMDrawLine(t *testing.T) {
 	for i := 0; i < imageWidth*imageHeight; i++ {
 		x := i % imageWidth
 		y := i / imageWidth
-		if ppm.data[y][x] != imagePPMDrawLine[i] {
-			t.Errorf("Pixel at (%d, %d) not drawn correctly wanted %v got %v", x, y, imagePPMDrawLine[i], ppm.data[y][x])
+		if ppm.At(x, y) != imagePPMDrawLine[i] {
+			t.Errorf("Pixel at (%d, %d) not drawn correctly wanted %v got %v", x, y, imagePPMDrawLine[i], ppm.At(x, y))
 		}
 	}
 }
@@ -610,8 +616,8 @@ func TestPPMDrawRectangle(t *testing.T) {
 	for i := 0; i < imageWidth*imageHeight; i++ {
 		x := i % imageWidth
 		y := i / imageWidth
-		if ppm.data[y][x] != imagePPMDrawRectangle[i] {
-			t.Errorf("Pixel at (%d, %d) not drawn correctly wanted %v got %v", x, y, imagePPMDrawRectangle[i], ppm.data[y][x])
+		if ppm.At(x, y) != imagePPMDrawRectangle[i] {
+			t.Errorf("Pixel at (%d, %d) not drawn correctly wanted %v got %v", x, y, imagePPMDrawRectangle[i], ppm.At(x, y))
 		}
 	}
 }
@@ -627,8 +633,8 @@ func TestPPMDrawFilledRectangle(t *testing.T) {
 	for i := 0; i < imageWidth*imageHeight; i++ {
 		x := i % imageWidth
 		y := i / imageWidth
-		if ppm.data[y][x] != imagePPMDrawFilledRectangle[i] {
-			t.Errorf("Pixel at (%d, %d) not drawn correctly wanted %v got %v", x, y, imagePPMDrawFilledRectangle[i], ppm.data[y][x])
+		if ppm.At(x, y) != imagePPMDrawFilledRectangle[i] {
+			t.Errorf("Pixel at (%d, %d) not drawn correctly wanted %v got %v", x, y, imagePPMDrawFilledRectangle[i], ppm.At(x, y))
 		}
 	}
 }
@@ -643,8 +649,8 @@ func TestPPMDrawCircle(t *testing.T) {
 	for i := 0; i < imageWidth*imageHeight; i++ {
 		x := i % imageWidth
 		y := i / imageWidth
-		if ppm.data[y][x] != imagePPMDrawCircle[i] {
-			t.Errorf("Pixel at (%d, %d) not drawn correctly wanted %v got %v", x, y, imagePPMDrawCircle[i], ppm.data[y][x])
+		if ppm.At(x, y) != imagePPMDrawCircle[i] {
+			t.Errorf("Pixel at (%d, %d) not drawn correctly wanted %v got %v", x, y, imagePPMDrawCircle[i], ppm.At(x, y))
 		}
 	}
 }
@@ -659,8 +665,8 @@ func TestPPMDrawFilledCircle(t *testing.T) {
 	for i := 0; i < imageWidth*imageHeight; i++ {
 		x := i % imageWidth
 		y := i / imageWidth
-		if ppm.data[y][x] != imagePPMDrawFilledCircle[i] {
-			t.Errorf("Pixel at (%d, %d) not drawn correctly wanted %v got %v", x, y, imagePPMDrawFilledCircle[i], ppm.data[y][x])
+		if ppm.At(x, y) != imagePPMDrawFilledCircle[i] {
+			t.Errorf("Pixel at (%d, %d) not drawn correctly wanted %v got %v", x, y, imagePPMDrawFilledCircle[i], ppm.At(x, y))
 		}
 	}
 }
@@ -675,8 +681,8 @@ func TestPPMDrawTriangle(t *testing.T) {
 	for i := 0; i < imageWidth*imageHeight; i++ {
 		x := i % imageWidth
 		y := i / imageWidth
-		if ppm.data[y][x] != imagePPMDrawTriangle[i] {
-			t.Errorf("Pixel at (%d, %d) not drawn correctly wanted %v got %v", x, y, imagePPMDrawTriangle[i], ppm.data[y][x])
+		if ppm.At(x, y) != imagePPMDrawTriangle[i] {
+			t.Errorf("Pixel at (%d, %d) not drawn correctly wanted %v got %v", x, y, imagePPMDrawTriangle[i], ppm.At(x, y))
 		}
 	}
 }
@@ -691,8 +697,8 @@ func TestPPMDrawFilledTriangle(t *testing.T) {
 	for i := 0; i < imageWidth*imageHeight; i++ {
 		x := i % imageWidth
 		y := i / imageWidth
-		if ppm.data[y][x] != imagePPMDrawFilledTriangle[i] {
-			t.Errorf("Pixel at (%d, %d) not drawn correctly wanted %v got %v", x, y, imagePPMDrawFilledTriangle[i], ppm.data[y][x])
+		if ppm.At(x, y) != imagePPMDrawFilledTriangle[i] {
+			t.Errorf("Pixel at (%d, %d) not drawn correctly wanted %v got %v", x, y, imagePPMDrawFilledTriangle[i], ppm.At(x, y))
 		}
 	}
 }
@@ -712,8 +718,8 @@ func TestPPMDrawPolygon(t *testing.T) {
 	for i := 0; i < imageWidth*imageHeight; i++ {
 		x := i % imageWidth
 		y := i / imageWidth
-		if ppm.data[y][x] != imagePPMDrawPolygon[i] {
-			t.Errorf("Pixel at (%d, %d) not drawn correctly wanted %v got %v", x, y, imagePPMDrawPolygon[i], ppm.data[y][x])
+		if ppm.At(x, y) != imagePPMDrawPolygon[i] {
+			t.Errorf("Pixel at (%d, %d) not drawn correctly wanted %v got %v", x, y, imagePPMDrawPolygon[i], ppm.At(x, y))
 		}
 	}
 }
@@ -733,8 +739,114 @@ func TestPPMDrawFilledPolygon(t *testing.T) {
 	for i := 0; i < imageWidth*imageHeight; i++ {
 		x := i % imageWidth
 		y := i / imageWidth
-		if ppm.data[y][x] != imagePPMDrawFilledPolygon[i] {
-			t.Errorf("Pixel at (%d, %d) not drawn correctly wanted %v got %v", x, y, imagePPMDrawFilledPolygon[i], ppm.data[y][x])
+		if ppm.At(x, y) != imagePPMDrawFilledPolygon[i] {
+			t.Errorf("Pixel at (%d, %d) not drawn correctly wanted %v got %v", x, y, imagePPMDrawFilledPolygon[i], ppm.At(x, y))
 		}
 	}
 }
+
+func TestPPMSave16Bit(t *testing.T) {
+	original := []byte("P6\n2 1\n4095\n\x0f\xff\x00\x00\x01\x00\x00\x01\x00\x02\x00\x03")
+	filename := t.TempDir() + "/test16.ppm"
+	err := os.WriteFile(filename, original, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ppm, err := ReadPPM(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ppm.max != 4095 {
+		t.Error("Max value not read correctly")
+	}
+	if ppm.At(0, 0) != (Pixel{4095, 0, 256}) || ppm.At(1, 0) != (Pixel{1, 2, 3}) {
+		t.Errorf("16-bit pixels not read correctly: %v", ppm.Row(0))
+	}
+	err = ppm.Save(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(original, saved) {
+		t.Error("16-bit P6 file not saved byte-for-byte")
+	}
+	pgm := ppm.ToPGM()
+	if pgm.max != 4095 || pgm.At(0, 0) != (4095+256)/3 {
+		t.Error("16-bit pixel not converted correctly")
+	}
+}
+
+func TestReadPPMLayout(t *testing.T) {
+	dir := t.TempDir()
+	layouts := []string{
+		"P3\n2 2\n255\n1 2 3\n4 5 6\n7 8 9\n10 11 12\n",
+		"P3 2 2 255 1 2 3 4 5 6 7 8 9 10 11 12",
+		"P3\n2 2\n255\n1\n2\n3 4\n5 6 7 8\n9 10 11\n12",
+	}
+	for _, layout := range layouts {
+		err := os.WriteFile(dir+"/layout.ppm", []byte(layout), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ppm, err := ReadPPM(dir + "/layout.ppm")
+		if err != nil {
+			t.Errorf("Layout %q not read: %v", layout, err)
+			continue
+		}
+		if ppm.At(0, 0) != (Pixel{1, 2, 3}) || ppm.At(1, 0) != (Pixel{4, 5, 6}) || ppm.At(0, 1) != (Pixel{7, 8, 9}) || ppm.At(1, 1) != (Pixel{10, 11, 12}) {
+			t.Errorf("Layout %q not read correctly: %v", layout, ppm.Pix)
+		}
+	}
+}
+
+func TestReadPPMSampleCount(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]ParseError{
+		"P3\n2 2\n255\n1 2 3 4 5 6 7 8 9 10 11\n":       {Line: 5, Column: 1, Expected: "12 samples", Found: "11", Err: ErrTruncated},
+		"P3\n2 2\n255\n1 2 3 4 5 6 7 8 9 10 11 12 13\n": {Line: 4, Column: 28, Expected: "12 samples", Found: "13", Err: ErrSyntax},
+	}
+	for content, expected := range files {
+		err := os.WriteFile(dir+"/count.ppm", []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = ReadPPM(dir + "/count.ppm")
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) {
+			t.Fatalf("Expected a ParseError, got %v", err)
+		}
+		if parseErr.File != dir+"/count.ppm" || parseErr.Line != expected.Line || parseErr.Column != expected.Column ||
+			parseErr.Expected != expected.Expected || parseErr.Found != expected.Found || !errors.Is(err, expected.Err) {
+			t.Errorf("Wrong error %+v", parseErr)
+		}
+	}
+}
+
+func TestPPMDecodeEncode(t *testing.T) {
+	original, err := os.ReadFile("./testImages/ppm/testP6.ppm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ppm, err := DecodePPM(bytes.NewReader(original))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < imageWidth*imageHeight; i++ {
+		x := i % imageWidth
+		y := i / imageWidth
+		if ppm.At(x, y) != imagePPMData[i] {
+			t.Errorf("Pixel at (%d, %d) not read correctly", x, y)
+		}
+	}
+	var buffer bytes.Buffer
+	err = ppm.Encode(&buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buffer.Bytes(), original) {
+		t.Error("Encoded data differs from the original")
+	}
+}
@@ -0,0 +1,145 @@
+package Netpbm
+
+import "image"
+
+/*
+Raster is the pixel store PBM, PGM and PPM are built on: T is bool, uint16
+and Pixel. Pix holds the pixels row after row and pixel (x, y) is
+Pix[y*Stride+x]; Stride is at least the width, and larger after Crop.
+Every operation written here works for the three formats at once.
+*/
+type Raster[T any] struct {
+	Pix           []T
+	Stride        int
+	width, height int
+}
+
+// NewRaster returns a raster of the given size with every pixel at the zero value of T.
+func NewRaster[T any](width, height int) *Raster[T] {
+	return &Raster[T]{Pix: make([]T, width*height), Stride: width, width: width, height: height}
+}
+
+func (r *Raster[T]) Size() (int, int) {
+	return r.width, r.height
+}
+
+func (r *Raster[T]) Bounds() image.Rectangle {
+	return image.Rect(0, 0, r.width, r.height)
+}
+
+// At returns the zero value of T outside the bounds, like image.Gray.
+func (r *Raster[T]) At(x, y int) T {
+	if x < 0 || y < 0 || x >= r.width || y >= r.height {
+		var zero T
+		return zero
+	}
+	return r.Pix[y*r.Stride+x]
+}
+
+// Set does nothing outside the bounds, so a pixel never lands in another row.
+func (r *Raster[T]) Set(x, y int, value T) {
+	if x < 0 || y < 0 || x >= r.width || y >= r.height {
+		return
+	}
+	r.Pix[y*r.Stride+x] = value
+}
+
+// Row returns row y as a slice of Pix, without copying.
+func (r *Raster[T]) Row(y int) []T {
+	return r.Pix[y*r.Stride : y*r.Stride+r.width]
+}
+
+// Clone returns a deep copy with a Stride equal to the width.
+func (r *Raster[T]) Clone() *Raster[T] {
+	clone := NewRaster[T](r.width, r.height)
+	for y := 0; y < r.height; y++ {
+		copy(clone.Row(y), r.Row(y))
+	}
+	return clone
+}
+
+// Map replaces every pixel with f of it.
+func (r *Raster[T]) Map(f func(T) T) {
+	for y := 0; y < r.height; y++ {
+		row := r.Row(y)
+		for x := range row {
+			row[x] = f(row[x])
+		}
+	}
+}
+
+/*
+Crop keeps the part of the raster inside rect, clipped to the bounds, without
+copying: Pix is resliced and Stride kept. The pixel at rect.Min becomes (0, 0).
+*/
+func (r *Raster[T]) Crop(rect image.Rectangle) {
+	rect = rect.Intersect(r.Bounds())
+	if rect.Empty() {
+		r.Pix, r.Stride, r.width, r.height = nil, 0, 0, 0
+		return
+	}
+	start := rect.Min.Y*r.Stride + rect.Min.X
+	end := (rect.Max.Y-1)*r.Stride + rect.Max.X
+	r.Pix = r.Pix[start:end:end]
+	r.width, r.height = rect.Dx(), rect.Dy()
+}
+
+func (r *Raster[T]) Flip() {
+	for y := 0; y < r.height; y++ {
+		row := r.Row(y)
+		for x, last := 0, len(row)-1; x < last; x, last = x+1, last-1 {
+			row[x], row[last] = row[last], row[x]
+		}
+	}
+}
+
+func (r *Raster[T]) Flop() {
+	/*Rows are swapped in place, a stretch at a time through a small buffer on the stack*/
+	var spare [flopChunk]T
+	for y := 0; y < r.height/2; y++ {
+		top, bottom := r.Row(y), r.Row(r.height-y-1)
+		for x := 0; x < len(top); x += len(spare) {
+			n := copy(spare[:], top[x:])
+			copy(top[x:x+n], bottom[x:x+n])
+			copy(bottom[x:x+n], spare[:n])
+		}
+	}
+}
+
+/*flopChunk is the number of pixels Flop swaps at a time.*/
+const flopChunk = 512
+
+/*rotateTile is the number of columns Rotate90CW copies at a time.*/
+const rotateTile = 32
+
+func (r *Raster[T]) Rotate90CW() {
+	/*
+		Source row y becomes destination column height-y-1. Four source rows are
+		read together, so that every destination row gets four neighbouring
+		pixels per write, and the columns go by tiles that keep those rows in cache.
+	*/
+	width, height := r.width, r.height
+	rotated := make([]T, width*height)
+	grouped := height - height%4
+	for x0 := 0; x0 < width; x0 += rotateTile {
+		x1 := min(x0+rotateTile, width)
+		for y := 0; y < grouped; y += 4 {
+			row0 := r.Pix[y*r.Stride+x0 : y*r.Stride+x1]
+			row1 := r.Pix[(y+1)*r.Stride+x0 : (y+1)*r.Stride+x1]
+			row2 := r.Pix[(y+2)*r.Stride+x0 : (y+2)*r.Stride+x1]
+			row3 := r.Pix[(y+3)*r.Stride+x0 : (y+3)*r.Stride+x1]
+			for i := range row0 {
+				column := rotated[(x0+i)*height+height-y-4 : (x0+i)*height+height-y]
+				column[3], column[2], column[1], column[0] = row0[i], row1[i], row2[i], row3[i]
+			}
+		}
+	}
+	for y := grouped; y < height; y++ {
+		for x, value := range r.Row(y) {
+			rotated[x*height+height-y-1] = value
+		}
+	}
+
+	r.Pix, r.Stride = rotated, height
+	r.width, r.height = height, width
+}
@@ -0,0 +1,119 @@
+package Netpbm
+
+import (
+	"bytes"
+	"image"
+	"testing"
+)
+
+func TestRasterCrop(t *testing.T) {
+	pgm := NewPGM(4, 3, 255)
+	for i := range pgm.Pix {
+		pgm.Pix[i] = uint16(i)
+	}
+	pgm.Crop(image.Rect(1, 1, 3, 5))
+	if width, height := pgm.Size(); width != 2 || height != 2 {
+		t.Fatalf("Cropped to %dx%d", width, height)
+	}
+	if pgm.Stride != 4 || pgm.At(0, 0) != 5 || pgm.At(1, 1) != 10 {
+		t.Errorf("Wrong crop %v, stride %d", pgm.Pix, pgm.Stride)
+	}
+
+	var buffer bytes.Buffer
+	if err := pgm.Encode(&buffer); err != nil {
+		t.Fatal(err)
+	}
+	if buffer.String() != "P2\n2 2\n255\n5 6\n9 10\n" {
+		t.Errorf("Wrong encoding of the crop %q", buffer.String())
+	}
+
+	clone := pgm.Clone().(*PGM)
+	if clone.Stride != 2 || len(clone.Pix) != 4 {
+		t.Errorf("Clone not compacted: stride %d, %d pixels", clone.Stride, len(clone.Pix))
+	}
+	pgm.Rotate90CW()
+	if pgm.At(0, 0) != 9 || pgm.At(1, 0) != 5 || pgm.At(0, 1) != 10 {
+		t.Errorf("Wrong rotation of the crop %v", pgm.Pix)
+	}
+	if clone.At(0, 0) != 5 {
+		t.Error("Clone shares its pixels")
+	}
+
+	pgm.Crop(image.Rect(5, 5, 6, 6))
+	if width, height := pgm.Size(); width != 0 || height != 0 {
+		t.Errorf("Crop outside the bounds is %dx%d", width, height)
+	}
+}
+
+func TestRasterOperations(t *testing.T) {
+	/*The same operations work on the three formats*/
+	pbm := NewPBM(3, 2)
+	pbm.Set(0, 0, true)
+	pbm.Rotate90CW()
+	if width, height := pbm.Size(); width != 2 || height != 3 || !pbm.At(1, 0) {
+		t.Errorf("Wrong bitmap rotation %v", pbm.Pix)
+	}
+	pbm.Flip()
+	pbm.Flop()
+	if !pbm.At(0, 2) {
+		t.Errorf("Wrong flip and flop %v", pbm.Pix)
+	}
+
+	ppm := NewPPM(2, 1, 100)
+	ppm.Set(1, 0, Pixel{100, 50, 0})
+	ppm.Map(func(pixel Pixel) Pixel { return Pixel{pixel.B, pixel.G, pixel.R} })
+	if ppm.At(1, 0) != (Pixel{0, 50, 100}) {
+		t.Errorf("Wrong map %v", ppm.Pix)
+	}
+	ppm.SetMaxValue(10)
+	pgm := ppm.ToPGM()
+	pgm.SetMaxValue(5)
+	if ppm.At(1, 0) != (Pixel{0, 5, 10}) || pgm.At(1, 0) != 3 {
+		t.Errorf("SetMaxValue differs: %v, %v", ppm.Pix, pgm.Pix)
+	}
+
+	raster := NewRaster[float32](2, 2)
+	raster.Set(1, 0, 0.5)
+	raster.Flop()
+	if raster.At(1, 1) != 0.5 {
+		t.Errorf("Wrong generic raster %v", raster.Pix)
+	}
+}
+
+func TestRasterBounds(t *testing.T) {
+	pgm := NewPGM(3, 3, 9)
+	pgm.Set(4, 0, 7)
+	pgm.Set(-1, 1, 7)
+	pgm.Set(0, 3, 7)
+	for _, value := range pgm.Pix {
+		if value != 0 {
+			t.Fatalf("Set outside the bounds wrote a pixel: %v", pgm.Pix)
+		}
+	}
+	pgm.Set(1, 1, 5)
+	if pgm.At(4, 0) != 0 || pgm.At(1, -1) != 0 || pgm.At(1, 1) != 5 {
+		t.Error("Wrong At outside the bounds")
+	}
+
+	/*After a crop the bounds are those of the cropped area*/
+	all := pgm.Pix
+	pgm.Crop(image.Rect(0, 0, 1, 1))
+	pgm.Set(1, 0, 7)
+	pgm.Set(0, 1, 7)
+	if all[1] != 0 || all[3] != 0 {
+		t.Errorf("Set wrote outside the crop: %v", all)
+	}
+
+	/*PAM and PFM keep their own rows but follow the same rule*/
+	pam := NewPAM(2, 2, 2, 255, "GRAYSCALE_ALPHA")
+	pam.Set(2, 0, []uint16{9, 9})
+	pam.Set(0, -1, []uint16{9, 9})
+	if tuple := pam.At(2, 0); tuple[0] != 0 || tuple[1] != 0 || pam.At(1, 0)[0] != 0 || pam.At(0, 1)[0] != 0 {
+		t.Error("Wrong PAM access outside the bounds")
+	}
+	pfm := NewPFM(2, 2, "Pf")
+	pfm.Set(0, 2, []float32{1})
+	if pfm.At(0, 2)[0] != 0 || pfm.At(1, 1)[0] != 0 {
+		t.Error("Wrong PFM access outside the bounds")
+	}
+}
@@ -0,0 +1,400 @@
+package Netpbm
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+)
+
+/*
+A row is width*Depth samples, one pixel after the other, whatever the
+format. Bitmap samples are 1 for a set (black) pixel and 0 otherwise, as in
+the file. RowReader and RowWriter only ever hold one row, so images larger
+than memory can be processed.
+*/
+
+// RowReader decodes an image one row at a time.
+type RowReader struct {
+	header *Header
+	tok    *tokenizer
+	row    int
+	buffer []byte /*one raw row, unused by the plain formats*/
+}
+
+// NewRowReader reads the header from r and stops before the first row.
+func NewRowReader(r io.Reader, opts ...DecodeOption) (*RowReader, error) {
+	tok := newTokenizer(r, opts...)
+	magicNumber, err := tok.nextMagic()
+	if err != nil {
+		return nil, err
+	}
+	header, err := decodeHeader(tok, magicNumber)
+	if err != nil {
+		return nil, err
+	}
+	/*Only one row is ever held, so the limits apply to a single row*/
+	if err := tok.checkSize(header.Width, 1, multiply(2, int64(header.Depth))); err != nil {
+		return nil, err
+	}
+	rr := &RowReader{header: header, tok: tok}
+	switch magicNumber {
+	case "P4":
+		rr.buffer = make([]byte, (header.Width+7)/8)
+	case "P5", "P6", "P7":
+		rr.buffer = make([]byte, header.Width*header.Depth*sampleSize(header.MaxValue))
+	}
+	return rr, nil
+}
+
+func (rr *RowReader) Header() *Header {
+	return rr.header
+}
+
+// RowLength is the number of samples in a row, Width*Depth.
+func (rr *RowReader) RowLength() int {
+	return rr.header.Width * rr.header.Depth
+}
+
+// NextRow decodes the next row into row, which must hold RowLength samples. It returns io.EOF after the last row.
+func (rr *RowReader) NextRow(row []uint16) error {
+	if rr.row >= rr.header.Height {
+		return io.EOF
+	}
+	if len(row) < rr.RowLength() {
+		return fmt.Errorf("row buffer too short: %d samples, need %d", len(row), rr.RowLength())
+	}
+	row = row[:rr.RowLength()]
+
+	switch rr.header.MagicNumber {
+	case "P4":
+		if err := rr.tok.readFull(rr.buffer, fmt.Sprintf("row %d", rr.row+1)); err != nil {
+			return err
+		}
+		for j := range row {
+			row[j] = uint16(rr.buffer[j/8]>>(7-uint(j%8))) & 1
+		}
+	case "P5", "P6", "P7":
+		if err := rr.tok.readFull(rr.buffer, fmt.Sprintf("row %d", rr.row+1)); err != nil {
+			return err
+		}
+		size := sampleSize(rr.header.MaxValue)
+		for j := range row {
+			if size == 2 {
+				row[j] = binary.BigEndian.Uint16(rr.buffer[j*2:])
+			} else {
+				row[j] = uint16(rr.buffer[j])
+			}
+			var err error
+			if row[j], err = rr.tok.checkRaw(len(rr.buffer)-j*size, row[j], rr.header.MaxValue); err != nil {
+				return err
+			}
+		}
+	case "P1":
+		for j := range row {
+			bit, err := rr.tok.nextBit()
+			if err != nil {
+				return err
+			}
+			row[j] = 0
+			if bit {
+				row[j] = 1
+			}
+		}
+	default:
+		for j := range row {
+			value, err := rr.tok.nextSample(rr.header.MaxValue)
+			if err != nil {
+				return err
+			}
+			row[j] = value
+		}
+	}
+	rr.row++
+	if rr.row == rr.header.Height && (rr.header.MagicNumber == "P1" || rr.header.MagicNumber == "P2" || rr.header.MagicNumber == "P3") {
+		return rr.tok.checkEnd(rr.header.Width*rr.header.Height*rr.header.Depth, rr.header.MagicNumber == "P1")
+	}
+	return nil
+}
+
+// RowWriter encodes an image one row at a time. Close must be called after the last row.
+type RowWriter struct {
+	header Header
+	writer *bufio.Writer
+	row    int
+	buffer []byte
+	plain  *plainWriter /*P1, P2 and P3*/
+}
+
+// NewRowWriter writes the header to w. A zero Depth is filled in for P1 to P6.
+func NewRowWriter(w io.Writer, header Header) (*RowWriter, error) {
+	depth := 1
+	switch header.MagicNumber {
+	case "P1", "P4":
+		header.MaxValue = 1
+	case "P2", "P5":
+	case "P3", "P6":
+		depth = 3
+	case "P7":
+		depth = header.Depth
+	default:
+		return nil, &UnknownFormatError{MagicNumber: header.MagicNumber}
+	}
+	if header.Depth == 0 {
+		header.Depth = depth
+	}
+	if header.Depth != depth || depth <= 0 {
+		return nil, fmt.Errorf("invalid depth %d for %s", header.Depth, header.MagicNumber)
+	}
+	if header.Width <= 0 || header.Height <= 0 {
+		return nil, fmt.Errorf("%w: %d x %d", ErrBadDimensions, header.Width, header.Height)
+	}
+	if header.MaxValue == 0 {
+		return nil, fmt.Errorf("%w: %d", ErrBadMaxValue, header.MaxValue)
+	}
+
+	rw := &RowWriter{header: header, writer: bufio.NewWriter(w)}
+	fmt.Fprintf(rw.writer, "%s\n", header.MagicNumber)
+	writeComments(rw.writer, header.Comments)
+	switch header.MagicNumber {
+	case "P1", "P4":
+		fmt.Fprintf(rw.writer, "%d %d\n", header.Width, header.Height)
+	case "P7":
+		fmt.Fprintf(rw.writer, "WIDTH %d\nHEIGHT %d\nDEPTH %d\nMAXVAL %d\n", header.Width, header.Height, header.Depth, header.MaxValue)
+		if header.TupleType != "" {
+			fmt.Fprintf(rw.writer, "TUPLTYPE %s\n", header.TupleType)
+		}
+		fmt.Fprint(rw.writer, "ENDHDR\n")
+	default:
+		fmt.Fprintf(rw.writer, "%d %d\n%d\n", header.Width, header.Height, header.MaxValue)
+	}
+
+	switch header.MagicNumber {
+	case "P4":
+		rw.buffer = make([]byte, (header.Width+7)/8)
+	case "P5", "P6", "P7":
+		rw.buffer = make([]byte, header.Width*header.Depth*sampleSize(header.MaxValue))
+	default:
+		rw.plain = newPlainWriter(rw.writer)
+	}
+	return rw, nil
+}
+
+// WriteRow encodes row, which must hold exactly Width*Depth samples.
+func (rw *RowWriter) WriteRow(row []uint16) error {
+	if rw.row >= rw.header.Height {
+		return fmt.Errorf("too many rows: the image has %d", rw.header.Height)
+	}
+	if len(row) != rw.header.Width*rw.header.Depth {
+		return fmt.Errorf("wrong row length: %d samples, expected %d", len(row), rw.header.Width*rw.header.Depth)
+	}
+	for _, value := range row {
+		if value > rw.header.MaxValue {
+			return fmt.Errorf("%w: %d above max value %d (row: %d)", ErrSampleOutOfRange, value, rw.header.MaxValue, rw.row)
+		}
+	}
+
+	switch rw.header.MagicNumber {
+	case "P4":
+		for i := range rw.buffer {
+			rw.buffer[i] = 0
+		}
+		for j, value := range row {
+			if value != 0 {
+				rw.buffer[j/8] |= 0x80 >> uint(j%8)
+			}
+		}
+	case "P5", "P6", "P7":
+		size := sampleSize(rw.header.MaxValue)
+		for j, value := range row {
+			if size == 2 {
+				binary.BigEndian.PutUint16(rw.buffer[j*2:], value)
+			} else {
+				rw.buffer[j] = uint8(value)
+			}
+		}
+	case "P1":
+		for _, value := range row {
+			rw.plain.bit(value != 0)
+		}
+	default:
+		for _, value := range row {
+			rw.plain.sample(value)
+		}
+	}
+	var err error
+	if rw.plain != nil {
+		err = rw.plain.endLine()
+	} else {
+		_, err = rw.writer.Write(rw.buffer)
+	}
+	if err != nil {
+		return err
+	}
+	rw.row++
+	return nil
+}
+
+// Close flushes the output and reports an error if fewer rows than Height were written.
+func (rw *RowWriter) Close() error {
+	if err := rw.writer.Flush(); err != nil {
+		return err
+	}
+	if rw.row != rw.header.Height {
+		return fmt.Errorf("wrong number of rows: wrote %d, expected %d", rw.row, rw.header.Height)
+	}
+	return nil
+}
+
+/*
+RowFilter transforms rows one at a time. Header gives the header of the
+filtered image, or an error if the filter does not apply to in; Row filters
+src, laid out as described by in, into dst.
+*/
+type RowFilter interface {
+	Header(in Header) (Header, error)
+	Row(in Header, src, dst []uint16)
+}
+
+// FilterRows copies an image from r to w through filters, holding only one row per filter in memory; opts apply to reading r.
+func FilterRows(r io.Reader, w io.Writer, filters []RowFilter, opts ...DecodeOption) error {
+	rr, err := NewRowReader(r, opts...)
+	if err != nil {
+		return err
+	}
+	headers := []Header{*rr.Header()}
+	rows := [][]uint16{make([]uint16, rr.RowLength())}
+	for _, filter := range filters {
+		header, err := filter.Header(headers[len(headers)-1])
+		if err != nil {
+			return err
+		}
+		headers = append(headers, header)
+		rows = append(rows, make([]uint16, header.Width*header.Depth))
+	}
+	rw, err := NewRowWriter(w, headers[len(headers)-1])
+	if err != nil {
+		return err
+	}
+	for {
+		err := rr.NextRow(rows[0])
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		for i, filter := range filters {
+			filter.Row(headers[i], rows[i], rows[i+1])
+		}
+		if err := rw.WriteRow(rows[len(rows)-1]); err != nil {
+			return err
+		}
+	}
+	return rw.Close()
+}
+
+func hasAlpha(header Header) bool {
+	return header.MagicNumber == "P7" && strings.HasSuffix(header.TupleType, "_ALPHA") && header.Depth > 1
+}
+
+type invertFilter struct{}
+
+// InvertRows inverts every sample except the alpha channel, like Invert.
+func InvertRows() RowFilter {
+	return invertFilter{}
+}
+
+func (invertFilter) Header(in Header) (Header, error) {
+	return in, nil
+}
+
+func (invertFilter) Row(in Header, src, dst []uint16) {
+	alpha := hasAlpha(in)
+	for j, value := range src {
+		if alpha && j%in.Depth == in.Depth-1 {
+			dst[j] = value
+		} else {
+			dst[j] = in.MaxValue - value
+		}
+	}
+}
+
+type grayFilter struct{}
+
+// GrayRows converts color rows to gray by averaging red, green and blue, like PPM.ToPGM.
+func GrayRows() RowFilter {
+	return grayFilter{}
+}
+
+func (grayFilter) Header(in Header) (Header, error) {
+	out := in
+	switch {
+	case in.MagicNumber == "P3":
+		out.MagicNumber = "P2"
+	case in.MagicNumber == "P6":
+		out.MagicNumber = "P5"
+	case in.MagicNumber == "P7" && in.Depth == 3 && !hasAlpha(in):
+		out.TupleType = "GRAYSCALE"
+	case in.MagicNumber == "P7" && in.Depth == 4 && hasAlpha(in):
+		out.TupleType = "GRAYSCALE_ALPHA"
+	default:
+		return in, fmt.Errorf("cannot convert %s with depth %d to gray", in.MagicNumber, in.Depth)
+	}
+	out.Depth = in.Depth - 2
+	return out, nil
+}
+
+func (grayFilter) Row(in Header, src, dst []uint16) {
+	for x := 0; x < in.Width; x++ {
+		pixel := src[x*in.Depth:]
+		dst[x*(in.Depth-2)] = uint16((uint32(pixel[0]) + uint32(pixel[1]) + uint32(pixel[2])) / 3)
+		if in.Depth == 4 {
+			dst[x*2+1] = pixel[3]
+		}
+	}
+}
+
+type thresholdFilter struct {
+	level uint16
+}
+
+/*
+ThresholdRows turns gray rows into a bitmap: samples below level become
+black. P2 and P5 give P1 and P4, a P7 GRAYSCALE image gives BLACKANDWHITE.
+*/
+func ThresholdRows(level uint16) RowFilter {
+	return thresholdFilter{level}
+}
+
+func (f thresholdFilter) Header(in Header) (Header, error) {
+	out := in
+	switch {
+	case in.MagicNumber == "P2":
+		out.MagicNumber = "P1"
+	case in.MagicNumber == "P5":
+		out.MagicNumber = "P4"
+	case in.MagicNumber == "P7" && in.Depth == 1:
+		out.TupleType = "BLACKANDWHITE"
+	default:
+		return in, fmt.Errorf("cannot threshold %s with depth %d", in.MagicNumber, in.Depth)
+	}
+	out.MaxValue = 1
+	return out, nil
+}
+
+func (f thresholdFilter) Row(in Header, src, dst []uint16) {
+	/*A PBM sample of 1 is black, a BLACKANDWHITE sample of 1 is white*/
+	black, white := uint16(1), uint16(0)
+	if in.MagicNumber == "P7" {
+		black, white = 0, 1
+	}
+	for j, value := range src {
+		if value < f.level {
+			dst[j] = black
+		} else {
+			dst[j] = white
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package Netpbm
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRowReader(t *testing.T) {
+	for _, filename := range []string{"./testImages/ppm/testP3.ppm", "./testImages/ppm/testP6.ppm"} {
+		file, err := os.Open(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+		rr, err := NewRowReader(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rr.Header().Depth != 3 || rr.RowLength() != imageWidth*3 {
+			t.Fatalf("%s: wrong header %+v", filename, rr.Header())
+		}
+		row := make([]uint16, rr.RowLength())
+		y := 0
+		for ; ; y++ {
+			err := rr.NextRow(row)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for x := 0; x < imageWidth; x++ {
+				pixel := Pixel{row[x*3], row[x*3+1], row[x*3+2]}
+				if pixel != imagePPMData[y*imageWidth+x] {
+					t.Fatalf("%s: wrong pixel at (%d, %d)", filename, x, y)
+				}
+			}
+		}
+		if y != imageHeight {
+			t.Errorf("%s: read %d rows", filename, y)
+		}
+	}
+}
+
+func TestRowWriterRoundTrip(t *testing.T) {
+	for _, filename := range []string{"./testImages/pbm/testP1.pbm", "./testImages/pbm/testP4.pbm", "./testImages/pgm/testP5.pgm", "./testImages/ppm/testP6.ppm"} {
+		original, err := os.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buffer bytes.Buffer
+		if err := FilterRows(bytes.NewReader(original), &buffer, nil); err != nil {
+			t.Fatal(err)
+		}
+		want, err := Decode(bytes.NewReader(original))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := Decode(&buffer)
+		if err != nil {
+			t.Fatalf("%s: %v", filename, err)
+		}
+		var wantBytes, gotBytes bytes.Buffer
+		want.Encode(&wantBytes)
+		got.Encode(&gotBytes)
+		if !bytes.Equal(wantBytes.Bytes(), gotBytes.Bytes()) {
+			t.Errorf("%s: image changed by the row copy", filename)
+		}
+	}
+}
+
+func TestRowWriterErrors(t *testing.T) {
+	var buffer bytes.Buffer
+	rw, err := NewRowWriter(&buffer, Header{MagicNumber: "P2", Width: 2, Height: 2, MaxValue: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rw.WriteRow([]uint16{1}) == nil {
+		t.Error("Expected an error for a short row")
+	}
+	if rw.WriteRow([]uint16{1, 11}) == nil {
+		t.Error("Expected an error for a sample above max")
+	}
+	if err := rw.WriteRow([]uint16{1, 10}); err != nil {
+		t.Fatal(err)
+	}
+	if rw.Close() == nil {
+		t.Error("Expected an error for a missing row")
+	}
+	if buffer.String() != "P2\n2 2\n10\n1 10\n" {
+		t.Errorf("Wrong output %q", buffer.String())
+	}
+}
+
+func TestFilterRows(t *testing.T) {
+	var buffer bytes.Buffer
+	err := FilterRows(strings.NewReader("P3\n3 1\n255\n255 255 255 30 60 90 0 0 3\n"), &buffer, []RowFilter{GrayRows(), InvertRows()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buffer.String() != "P2\n3 1\n255\n0 195 254\n" {
+		t.Errorf("Wrong output %q", buffer.String())
+	}
+
+	buffer.Reset()
+	err = FilterRows(strings.NewReader("P5\n4 1\n255\n\x00\x7f\x80\xff"), &buffer, []RowFilter{ThresholdRows(128)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buffer.String() != "P4\n4 1\n\xc0" {
+		t.Errorf("Wrong output %q", buffer.String())
+	}
+
+	if FilterRows(strings.NewReader("P2\n1 1\n255\n0\n"), &buffer, []RowFilter{GrayRows()}) == nil {
+		t.Error("Expected an error converting a graymap to gray")
+	}
+
+	/*Options reach the row reader: Lenient mode clamps the sample that Strict rejects*/
+	buffer.Reset()
+	if FilterRows(strings.NewReader("P2\n2 1\n10\n3 11\n"), &buffer, []RowFilter{InvertRows()}) == nil {
+		t.Error("Expected an error for a sample above max")
+	}
+	buffer.Reset()
+	err = FilterRows(strings.NewReader("P2\n2 1\n10\n3 11\n"), &buffer, []RowFilter{InvertRows()}, WithMode(Lenient))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buffer.String() != "P2\n2 1\n10\n7 0\n" {
+		t.Errorf("Wrong output %q", buffer.String())
+	}
+}
+
+func TestFilterRowsPAM(t *testing.T) {
+	pam := NewPAM(2, 1, 4, 255, "RGB_ALPHA")
+	pam.Set(0, 0, []uint16{30, 60, 90, 128})
+	pam.Set(1, 0, []uint16{255, 255, 255, 255})
+	var input, output bytes.Buffer
+	pam.Encode(&input)
+	if err := FilterRows(&input, &output, []RowFilter{GrayRows(), InvertRows()}); err != nil {
+		t.Fatal(err)
+	}
+	gray, err := DecodePAM(&output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gray.TupleType() != "GRAYSCALE_ALPHA" || gray.At(0, 0)[0] != 195 || gray.At(0, 0)[1] != 128 || gray.At(1, 0)[0] != 0 {
+		t.Errorf("Wrong result %v", gray.data)
+	}
+}
@@ -0,0 +1,106 @@
+package Netpbm
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+/*
+SaveOption tunes the Save methods. By default Save truncates and rewrites
+the file in place, creating it with mode 0666 before the umask. A filename
+ending in .gz is written gzip compressed.
+*/
+type SaveOption func(*saveOptions)
+
+type saveOptions struct {
+	atomic  bool
+	perm    os.FileMode
+	setPerm bool
+}
+
+// Atomic makes Save write a temporary file next to the destination, sync it
+// and rename it over the destination, so a crash leaves either the old image
+// or the new one, never a truncated file. Unless WithPerm is given the file
+// keeps the mode of the image it replaces, or gets 0644.
+func Atomic() SaveOption {
+	return func(o *saveOptions) { o.atomic = true }
+}
+
+// WithPerm sets the mode of the saved file. Without Atomic it only applies when the file is created.
+func WithPerm(perm os.FileMode) SaveOption {
+	return func(o *saveOptions) { o.perm, o.setPerm = perm, true }
+}
+
+/*saveFile writes filename with encode, the body shared by every Save method.*/
+func saveFile(filename string, encode func(io.Writer) error, opts []SaveOption) error {
+	options := saveOptions{perm: 0666}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	encode, err := compressFor(filename, encode)
+	if err != nil {
+		return err
+	}
+	if options.atomic {
+		return saveAtomic(filename, encode, options)
+	}
+
+	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, options.perm)
+	if err != nil {
+		return err
+	}
+	if err := encode(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func saveAtomic(filename string, encode func(io.Writer) error, options saveOptions) (err error) {
+	perm := options.perm
+	if !options.setPerm {
+		perm = 0644
+		if info, statErr := os.Stat(filename); statErr == nil {
+			perm = info.Mode().Perm()
+		}
+	}
+
+	dir, base := filepath.Split(filename)
+	if dir == "" {
+		dir = "."
+	}
+	/*The temporary file is a sibling so that the rename stays on one file system*/
+	file, err := os.CreateTemp(dir, "."+base+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			file.Close()
+			os.Remove(file.Name())
+		}
+	}()
+
+	if err = file.Chmod(perm); err != nil {
+		return err
+	}
+	if err = encode(file); err != nil {
+		return err
+	}
+	if err = file.Sync(); err != nil {
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(file.Name(), filename); err != nil {
+		return err
+	}
+	/*Syncing the directory makes the rename itself durable, not every system allows it*/
+	if d, dirErr := os.Open(dir); dirErr == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package Netpbm
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSaveAtomic(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "image.pgm")
+	if err := os.WriteFile(filename, []byte("old"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	pgm := NewPGM(2, 2, 255)
+	pgm.Set(1, 1, 200)
+	if err := pgm.Save(filename, Atomic()); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := ReadPGM(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.At(1, 1) != 200 {
+		t.Error("Wrong data")
+	}
+	info, err := os.Stat(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0640 {
+		t.Errorf("Mode %v not kept", info.Mode().Perm())
+	}
+
+	if err := pgm.Save(filename, Atomic(), WithPerm(0600)); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := os.Stat(filename); info.Mode().Perm() != 0600 {
+		t.Errorf("Mode %v, expected 0600", info.Mode().Perm())
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("Temporary files left behind: %v", entries)
+	}
+}
+
+func TestSaveAtomicFailure(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "image.pbm")
+	if err := os.WriteFile(filename, []byte("P1\n1 1\n1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	failure := errors.New("disk full")
+	err := saveFile(filename, func(w io.Writer) error {
+		w.Write([]byte("P1\n"))
+		return failure
+	}, []SaveOption{Atomic()})
+	if err != failure {
+		t.Fatalf("Expected the encode error, got %v", err)
+	}
+	content, _ := os.ReadFile(filename)
+	if string(content) != "P1\n1 1\n1\n" {
+		t.Errorf("Original image damaged: %q", content)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("Temporary files left behind: %v", entries)
+	}
+}
+
+func TestSavePerm(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "image.ppm")
+	if err := NewPPM(1, 1, 255).Save(filename, WithPerm(0600)); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := os.Stat(filename); info.Mode().Perm() != 0600 {
+		t.Errorf("Mode %v, expected 0600", info.Mode().Perm())
+	}
+	if err := NewPPM(1, 1, 255).Save(filepath.Join(filename, "missing")); err == nil {
+		t.Error("Expected an error")
+	}
+}
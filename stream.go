@@ -0,0 +1,106 @@
+package Netpbm
+
+import (
+	"fmt"
+	"io"
+)
+
+/*
+StreamDecoder walks a stream of concatenated images, which may be of mixed
+types, the way the Netpbm tools do:
+
+	dec := NewStreamDecoder(r)
+	for dec.Next() {
+		frame := dec.Image()
+		...
+	}
+	if err := dec.Err(); err != nil {
+		...
+	}
+*/
+type StreamDecoder struct {
+	tok   *tokenizer
+	img   Image
+	count int
+	err   error
+}
+
+// NewStreamDecoder returns a StreamDecoder reading from r.
+func NewStreamDecoder(r io.Reader, opts ...DecodeOption) *StreamDecoder {
+	return &StreamDecoder{tok: newTokenizer(r, opts...)}
+}
+
+// Next decodes the next image. It returns false at the end of the stream or on the first error.
+func (d *StreamDecoder) Next() bool {
+	if d.err != nil {
+		return false
+	}
+	d.img = nil
+	d.tok.comments = nil
+	d.tok.warned = nil
+	/*Whitespace or comments after the last image are not another image*/
+	if err := d.tok.skip(); err != nil {
+		if err != io.EOF {
+			d.err = err
+		}
+		return false
+	}
+	magicNumber, err := d.tok.nextMagic()
+	if err != nil {
+		d.err = fmt.Errorf("image %d: %w", d.count+1, err)
+		return false
+	}
+	img, err := decodeImage(d.tok, magicNumber)
+	if err != nil {
+		d.err = fmt.Errorf("image %d: %w", d.count+1, err)
+		return false
+	}
+	d.img = img
+	d.count++
+	return true
+}
+
+// Image returns the image decoded by the last successful call to Next.
+func (d *StreamDecoder) Image() Image {
+	return d.img
+}
+
+// Count is the number of images decoded so far.
+func (d *StreamDecoder) Count() int {
+	return d.count
+}
+
+// Err returns the error that stopped Next, or nil at a clean end of stream.
+func (d *StreamDecoder) Err() error {
+	return d.err
+}
+
+/*StreamEncoder appends images to a stream, each one in its own format.*/
+type StreamEncoder struct {
+	w     io.Writer
+	count int
+	err   error
+}
+
+// NewStreamEncoder returns a StreamEncoder writing to w.
+func NewStreamEncoder(w io.Writer) *StreamEncoder {
+	return &StreamEncoder{w: w}
+}
+
+// Encode appends img to the stream. After a failed write every later call returns the same error.
+func (e *StreamEncoder) Encode(img Image) error {
+	if e.err != nil {
+		return e.err
+	}
+	if err := img.Encode(e.w); err != nil {
+		e.err = fmt.Errorf("image %d: %w", e.count+1, err)
+		return e.err
+	}
+	e.count++
+	return nil
+}
+
+// Count is the number of images written so far.
+func (e *StreamEncoder) Count() int {
+	return e.count
+}
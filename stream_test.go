@@ -0,0 +1,70 @@
+package Netpbm
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestStreamDecoder(t *testing.T) {
+	stream := "P1\n2 1\n10\n" +
+		"P2\n# second\n2 1\n255\n0 255\n" +
+		"P6\n1 1\n255\n\x01\x02\x03" +
+		"P3\n1 1\n255\n4 5 6\n\n"
+	dec := NewStreamDecoder(strings.NewReader(stream))
+	var images []Image
+	for dec.Next() {
+		images = append(images, dec.Image())
+	}
+	if err := dec.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(images) != 4 || dec.Count() != 4 {
+		t.Fatalf("Expected 4 images, got %d", len(images))
+	}
+	if !images[0].(*PBM).At(0, 0) || images[1].(*PGM).At(1, 0) != 255 {
+		t.Error("Wrong data in the first images")
+	}
+	if images[2].(*PPM).At(0, 0) != (Pixel{1, 2, 3}) || images[3].(*PPM).At(0, 0) != (Pixel{4, 5, 6}) {
+		t.Error("Wrong data in the last images")
+	}
+}
+
+func TestStreamDecoderError(t *testing.T) {
+	dec := NewStreamDecoder(strings.NewReader("P1\n1 1\n1\nP6\n2 2\n255\n\x00"))
+	if !dec.Next() {
+		t.Fatal(dec.Err())
+	}
+	if dec.Next() {
+		t.Error("Truncated image should not be decoded")
+	}
+	if dec.Err() == nil || dec.Count() != 1 {
+		t.Errorf("Expected an error after one image, got %v", dec.Err())
+	}
+}
+
+func TestStreamEncoder(t *testing.T) {
+	ppm, err := ReadPPM("./testImages/ppm/testP6.ppm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buffer bytes.Buffer
+	enc := NewStreamEncoder(&buffer)
+	for i := 0; i < 3; i++ {
+		if err := enc.Encode(ppm); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dec := NewStreamDecoder(&buffer)
+	for dec.Next() {
+		frame := dec.Image().(*PPM)
+		for i := 0; i < imageWidth*imageHeight; i++ {
+			if frame.At(i%imageWidth, i/imageWidth) != imagePPMData[i] {
+				t.Fatalf("Frame %d differs at pixel %d", dec.Count(), i)
+			}
+		}
+	}
+	if dec.Err() != nil || dec.Count() != 3 {
+		t.Errorf("Expected 3 frames, got %d (%v)", dec.Count(), dec.Err())
+	}
+}
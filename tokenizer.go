@@ -0,0 +1,411 @@
+package Netpbm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+/*
+tokenizer splits a Netpbm header, and the raster of the plain formats, into
+tokens. Any whitespace separates tokens and a '#' starts a comment that runs
+to the end of the line, wherever it appears.
+*/
+type tokenizer struct {
+	reader *bufio.Reader
+	/*offset counts every byte consumed, line and column only follow the text*/
+	offset       int64
+	line, column int
+	previous     position /*restored by unreadByte*/
+	start        position /*where the last token began*/
+	comments     []string
+	options      decodeOptions
+	warned       map[error]bool /*kinds of problem already recorded as a warning for this image*/
+	compressed   bool           /*offsets then count decompressed bytes*/
+}
+
+type position struct {
+	offset       int64
+	line, column int
+}
+
+func newTokenizer(r io.Reader, opts ...DecodeOption) *tokenizer {
+	options := newDecodeOptions(opts)
+	t := &tokenizer{reader: bufio.NewReader(r), line: 1, options: options}
+	if options.decompress {
+		t.reader, t.compressed = decompress(t.reader)
+	}
+	return t
+}
+
+/*dataOffset is the offset of the next byte in the input itself, -1 when it was decompressed.*/
+func (t *tokenizer) dataOffset() int64 {
+	if t.compressed {
+		return -1
+	}
+	return t.offset
+}
+
+/*here is the position of the next byte.*/
+func (t *tokenizer) here() position {
+	return position{t.offset, t.line, t.column + 1}
+}
+
+/*errorAt builds a ParseError at pos.*/
+func (t *tokenizer) errorAt(pos position, expected, found string, err error) *ParseError {
+	return &ParseError{File: t.options.filename, Offset: pos.offset, Line: pos.line, Column: pos.column, Expected: expected, Found: found, Err: err}
+}
+
+/*rawErrorAt builds a ParseError inside a raw raster, where only the offset makes sense.*/
+func (t *tokenizer) rawErrorAt(offset int64, expected, found string, err error) *ParseError {
+	return &ParseError{File: t.options.filename, Offset: offset, Expected: expected, Found: found, Err: err}
+}
+
+/*
+tolerate reports whether Lenient mode lets decoding go on after problem.
+The first problem of each kind in an image is recorded as a warning.
+*/
+func (t *tokenizer) tolerate(problem *ParseError) bool {
+	if t.options.mode != Lenient {
+		return false
+	}
+	if !t.warned[problem.Err] {
+		if t.warned == nil {
+			t.warned = map[error]bool{}
+		}
+		t.warned[problem.Err] = true
+		if t.options.warnings != nil {
+			*t.options.warnings = append(*t.options.warnings, problem)
+		}
+	}
+	return true
+}
+
+/*truncated reports whether err is a ParseError for input that ended too early.*/
+func truncated(err error) (*ParseError, bool) {
+	parseErr, ok := err.(*ParseError)
+	return parseErr, ok && parseErr.Err == ErrTruncated
+}
+
+/*headerComments copies the comments read so far, before raster comments are added to them.*/
+func (t *tokenizer) headerComments() []string {
+	return append([]string(nil), t.comments...)
+}
+
+/*tokenError reports a problem with the last token.*/
+func (t *tokenizer) tokenError(expected, found string, err error) *ParseError {
+	return t.errorAt(t.start, expected, found, err)
+}
+
+/*endError reports that the input stopped while expected was still missing.*/
+func (t *tokenizer) endError(expected string, err error) *ParseError {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return t.errorAt(t.here(), expected, "end of file", ErrTruncated)
+	}
+	return t.errorAt(t.here(), expected, err.Error(), err)
+}
+
+func isWhitespace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\v' || c == '\f' || c == '\r'
+}
+
+func (t *tokenizer) readByte() (byte, error) {
+	c, err := t.reader.ReadByte()
+	if err == nil {
+		t.previous = position{t.offset, t.line, t.column}
+		t.offset++
+		if c == '\n' {
+			t.line++
+			t.column = 0
+		} else {
+			t.column++
+		}
+	}
+	return c, err
+}
+
+func (t *tokenizer) unreadByte() error {
+	if err := t.reader.UnreadByte(); err != nil {
+		return err
+	}
+	t.offset, t.line, t.column = t.previous.offset, t.previous.line, t.previous.column
+	return nil
+}
+
+/*
+readFull reads a raw block, expected names it in the error if the input is
+too short. In Lenient mode a short block is padded with zeros.
+*/
+func (t *tokenizer) readFull(buffer []byte, expected string) error {
+	n, err := io.ReadFull(t.reader, buffer)
+	t.offset += int64(n)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		problem := t.rawErrorAt(t.offset, expected, "end of file", ErrTruncated)
+		if !t.tolerate(problem) {
+			return problem
+		}
+		for i := n; i < len(buffer); i++ {
+			buffer[i] = 0
+		}
+		return nil
+	}
+	if err != nil {
+		return t.rawErrorAt(t.offset, expected, err.Error(), err)
+	}
+	return nil
+}
+
+/*skip moves past whitespace and comments, keeping the comment text, and leaves the reader on the next token byte.*/
+func (t *tokenizer) skip() error {
+	for {
+		c, err := t.readByte()
+		if err != nil {
+			return err
+		}
+		if c == '#' {
+			comment := []byte{}
+			for {
+				if c, err = t.readByte(); err != nil {
+					break
+				}
+				if c == '\n' || c == '\r' {
+					break
+				}
+				comment = append(comment, c)
+			}
+			t.comments = append(t.comments, strings.TrimSpace(string(comment)))
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if !isWhitespace(c) {
+			return t.unreadByte()
+		}
+	}
+}
+
+/*
+next returns the next token. The single whitespace byte ending the token is
+consumed, so after the last header token the reader sits at the first byte
+of a raw raster.
+*/
+func (t *tokenizer) next() (string, error) {
+	if err := t.skip(); err != nil {
+		return "", err
+	}
+	t.start = t.here()
+	token := []byte{}
+	for {
+		c, err := t.readByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		if c == '#' {
+			t.unreadByte()
+			break
+		}
+		if isWhitespace(c) {
+			break
+		}
+		token = append(token, c)
+	}
+	return string(token), nil
+}
+
+/*nextInt reads the next token as a non-negative decimal integer, what names it in errors.*/
+func (t *tokenizer) nextInt(what string, invalid error) (int, error) {
+	token, err := t.next()
+	if err != nil {
+		return 0, t.endError(what, err)
+	}
+	value, err := parseDigits(token)
+	if err != nil {
+		return 0, t.tokenError(what, fmt.Sprintf("%q", token), invalid)
+	}
+	return value, nil
+}
+
+/*parseDigits reads a decimal integer made of digits only: strconv.Atoi alone would take a sign.*/
+func parseDigits(token string) (int, error) {
+	for i := 0; i < len(token); i++ {
+		if token[i] < '0' || token[i] > '9' {
+			return 0, strconv.ErrSyntax
+		}
+	}
+	return strconv.Atoi(token)
+}
+
+/*nextMagic reads the magic number.*/
+func (t *tokenizer) nextMagic() (string, error) {
+	magicNumber, err := t.next()
+	if err != nil {
+		return "", t.endError("magic number", err)
+	}
+	return magicNumber, nil
+}
+
+/*nextDimension reads a width or height, which must be positive.*/
+func (t *tokenizer) nextDimension(what string) (int, error) {
+	value, err := t.nextInt(what, ErrBadDimensions)
+	if err != nil {
+		return 0, err
+	}
+	if value == 0 {
+		return 0, t.tokenError("positive "+what, "0", ErrBadDimensions)
+	}
+	return value, nil
+}
+
+/*nextMaxValue reads a max value, 1 to 65535.*/
+func (t *tokenizer) nextMaxValue() (uint16, error) {
+	value, err := t.nextInt("max value", ErrBadMaxValue)
+	if err != nil {
+		return 0, err
+	}
+	if value < 1 || value > 65535 {
+		problem := t.tokenError("max value from 1 to 65535", strconv.Itoa(value), ErrBadMaxValue)
+		/*Lenient mode can clamp a max value that is too large, not a zero one*/
+		if value == 0 || !t.tolerate(problem) {
+			return 0, problem
+		}
+		return 65535, nil
+	}
+	return uint16(value), nil
+}
+
+/*
+nextSample reads one plain sample, which must not exceed max. Lenient mode
+clamps it to max and reads 0 once the input has ended.
+*/
+func (t *tokenizer) nextSample(max uint16) (uint16, error) {
+	value, err := t.nextInt("sample", ErrSyntax)
+	if problem, ok := truncated(err); ok && t.tolerate(problem) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if value > int(max) {
+		problem := t.tokenError(fmt.Sprintf("sample from 0 to %d", max), strconv.Itoa(value), ErrSampleOutOfRange)
+		if !t.tolerate(problem) {
+			return 0, problem
+		}
+		return max, nil
+	}
+	return uint16(value), nil
+}
+
+/*checkRaw checks a raw sample that started back bytes before the current offset, clamping it in Lenient mode.*/
+func (t *tokenizer) checkRaw(back int, value, max uint16) (uint16, error) {
+	if value <= max {
+		return value, nil
+	}
+	problem := t.rawErrorAt(t.offset-int64(back), fmt.Sprintf("sample from 0 to %d", max), strconv.Itoa(int(value)), ErrSampleOutOfRange)
+	if !t.tolerate(problem) {
+		return 0, problem
+	}
+	return max, nil
+}
+
+/*
+checkEnd makes sure a plain raster of expected samples is not followed by
+more. The extra samples are read, so that the error can tell how many were
+found and Lenient mode can skip them; bits counts every P1 digit as a sample.
+*/
+func (t *tokenizer) checkEnd(expected int, bits bool) error {
+	if !t.peekNumber() {
+		return nil
+	}
+	pos := t.here()
+	found := expected
+	for t.peekNumber() {
+		token, err := t.next()
+		if err != nil {
+			break
+		}
+		if !bits {
+			found++
+			continue
+		}
+		for _, c := range []byte(token) {
+			if c >= '0' && c <= '9' {
+				found++
+			}
+		}
+	}
+	problem := t.errorAt(pos, fmt.Sprintf("%d samples", expected), fmt.Sprintf("%d", found), ErrSyntax)
+	if !t.tolerate(problem) {
+		return problem
+	}
+	return nil
+}
+
+/*
+nextBit reads one P1 sample; the spec does not require whitespace between
+them. Lenient mode takes any other digit as 1 and reads 0 once the input
+has ended.
+*/
+func (t *tokenizer) nextBit() (bool, error) {
+	err := t.skip()
+	if err == nil {
+		t.start = t.here()
+	}
+	var c byte
+	if err == nil {
+		c, err = t.readByte()
+	}
+	if err != nil {
+		problem := t.endError("bit", err)
+		if _, ok := truncated(problem); ok && t.tolerate(problem) {
+			return false, nil
+		}
+		return false, problem
+	}
+	switch {
+	case c == '0':
+		return false, nil
+	case c == '1':
+		return true, nil
+	case c >= '2' && c <= '9':
+		problem := t.tokenError("0 or 1", fmt.Sprintf("%q", c), ErrSampleOutOfRange)
+		if !t.tolerate(problem) {
+			return false, problem
+		}
+		return true, nil
+	}
+	return false, t.tokenError("bit", fmt.Sprintf("%q", c), ErrSyntax)
+}
+
+/*peekNumber skips whitespace and comments and reports whether a number follows.*/
+func (t *tokenizer) peekNumber() bool {
+	if err := t.skip(); err != nil {
+		return false
+	}
+	c, err := t.reader.Peek(1)
+	return err == nil && c[0] >= '0' && c[0] <= '9'
+}
+
+/*nextLine reads up to the end of the line, the PAM header is line oriented.*/
+func (t *tokenizer) nextLine() (string, error) {
+	t.start = t.here()
+	line := []byte{}
+	for {
+		c, err := t.readByte()
+		if err == io.EOF && len(line) > 0 {
+			return string(line), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if c == '\n' {
+			return string(line), nil
+		}
+		line = append(line, c)
+	}
+}
@@ -0,0 +1,79 @@
+package Netpbm
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestTokenizer(t *testing.T) {
+	tok := newTokenizer(strings.NewReader("P2\n# created by hand\n15\t# width\n 15\n#max\n11 0#comment\n2"))
+	expected := []string{"P2", "15", "15", "11", "0", "2"}
+	for _, want := range expected {
+		got, err := tok.next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("Wrong token, expected %q got %q", want, got)
+		}
+	}
+	if _, err := tok.next(); err == nil {
+		t.Error("Expected EOF after the last token")
+	}
+}
+
+func TestTokenizerRawData(t *testing.T) {
+	tok := newTokenizer(strings.NewReader("P5 1 1 255\n\n"))
+	for i := 0; i < 4; i++ {
+		if _, err := tok.next(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c, err := tok.reader.ReadByte()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c != '\n' {
+		t.Errorf("Raw data should start right after the max value, got %q", c)
+	}
+}
+
+func TestTokenizerReaders(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(dir+"/test.pbm", []byte("P1\n# comment\n3\n2 # size\n010\n1 0 1\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pbm, err := ReadPBM(dir + "/test.pbm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pbm.width != 3 || pbm.height != 2 || !pbm.At(1, 0) || !pbm.At(2, 1) || pbm.At(1, 1) {
+		t.Error("P1 with comments and compact rows not read correctly")
+	}
+
+	err = os.WriteFile(dir+"/test.pgm", []byte("P5 # raw\n2 # width\n1\n# max\n255\n\x0a\x20"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pgm, err := ReadPGM(dir + "/test.pgm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pgm.At(0, 0) != 10 || pgm.At(1, 0) != 32 {
+		t.Error("P5 with comments not read correctly")
+	}
+
+	err = os.WriteFile(dir+"/test.ppm", []byte("P3 2 1 255 # one line header\n1 2\n3 4 # comment\n5 6"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ppm, err := ReadPPM(dir + "/test.ppm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ppm.At(0, 0) != (Pixel{1, 2, 3}) || ppm.At(1, 0) != (Pixel{4, 5, 6}) {
+		t.Error("P3 with comments not read correctly")
+	}
+}